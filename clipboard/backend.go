@@ -0,0 +1,98 @@
+package clipboard
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// A way to reach the clipboard of the system
+type Backend interface {
+	Read() (string, error)
+	Write(text string) error
+}
+
+type (
+	// A clipboard living in the process memory.
+	// Always available, but not shared with the other applications
+	MemoryBackend struct {
+		mutex sync.Mutex
+		data  string
+	}
+
+	// A clipboard reached through external programs.
+	// The text is written to the stdin of WriteCmd and
+	// read from the stdout of ReadCmd
+	CommandBackend struct {
+		ReadCmd  []string
+		WriteCmd []string
+	}
+
+	// Use the primary backend and fall back to an in-process
+	// clipboard when it fails (no display server running, ...).
+	// A failed write is still reported, the other applications
+	// won't see the text
+	fallbackBackend struct {
+		primary Backend
+		memory  MemoryBackend
+	}
+)
+
+func (m *MemoryBackend) Read() (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.data, nil
+}
+
+func (m *MemoryBackend) Write(text string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data = text
+	return nil
+}
+
+func (c CommandBackend) Read() (string, error) {
+	out, err := exec.Command(c.ReadCmd[0], c.ReadCmd[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (c CommandBackend) Write(text string) error {
+	cmd := exec.Command(c.WriteCmd[0], c.WriteCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// Check if all the programs needed by the backend are installed
+func (c CommandBackend) available() bool {
+	for _, name := range []string{c.ReadCmd[0], c.WriteCmd[0]} {
+		if _, err := exec.LookPath(name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fallbackBackend) Read() (string, error) {
+	if f.primary != nil {
+		if data, err := f.primary.Read(); err == nil {
+			return data, nil
+		}
+	}
+	return f.memory.Read()
+}
+
+func (f *fallbackBackend) Write(text string) error {
+	f.memory.Write(text)
+	if f.primary == nil {
+		return nil
+	}
+	// The text is still reachable in-process if the primary fails
+	if err := f.primary.Write(text); err != nil {
+		return fmt.Errorf("%w, the text is only kept in the editor", err)
+	}
+	return nil
+}
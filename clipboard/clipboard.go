@@ -1,7 +1,12 @@
 package clipboard
 
-// NOTE: Only supports Windows for now
+// NOTE: Windows talks to the Win32 clipboard directly.
+// The other platforms go through a Backend, see SetBackend
 
 func ReadClipboard() (string, error) {
 	return readClipboard()
 }
+
+func WriteClipboard(text string) error {
+	return writeClipboard(text)
+}
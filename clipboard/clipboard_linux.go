@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package clipboard
+
+import "os"
+
+var (
+	waylandBackend = CommandBackend{
+		ReadCmd:  []string{"wl-paste", "--no-newline"},
+		WriteCmd: []string{"wl-copy"},
+	}
+	xclipBackend = CommandBackend{
+		ReadCmd:  []string{"xclip", "-selection", "clipboard", "-out"},
+		WriteCmd: []string{"xclip", "-selection", "clipboard", "-in"},
+	}
+	xselBackend = CommandBackend{
+		ReadCmd:  []string{"xsel", "--clipboard", "--output"},
+		WriteCmd: []string{"xsel", "--clipboard", "--input"},
+	}
+)
+
+// Pick the first clipboard program available for the running display
+// server. Returns nil when headless, only the in-process clipboard is used then
+func defaultBackend() Backend {
+	if os.Getenv("WAYLAND_DISPLAY") != "" && waylandBackend.available() {
+		return waylandBackend
+	}
+	if os.Getenv("DISPLAY") != "" {
+		for _, b := range []CommandBackend{xclipBackend, xselBackend} {
+			if b.available() {
+				return b
+			}
+		}
+	}
+	return nil
+}
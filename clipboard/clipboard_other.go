@@ -0,0 +1,16 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package clipboard
+
+var pasteboardBackend = CommandBackend{
+	ReadCmd:  []string{"pbpaste"},
+	WriteCmd: []string{"pbcopy"},
+}
+
+func defaultBackend() Backend {
+	if pasteboardBackend.available() {
+		return pasteboardBackend
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package clipboard
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+type failingBackend struct{}
+
+func (f failingBackend) Read() (string, error)   { return "", errors.New("no display") }
+func (f failingBackend) Write(text string) error { return errors.New("no display") }
+
+func TestFallbackBackend(t *testing.T) {
+	b := &fallbackBackend{primary: failingBackend{}}
+	if err := b.Write("hello"); err == nil {
+		t.Error("The failure of the primary backend should be reported")
+	}
+	data, err := b.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "hello" {
+		t.Errorf("Expected the in-process clipboard content, got %q", data)
+	}
+}
+
+func TestCommandBackend(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("No shell available")
+	}
+	path := filepath.Join(t.TempDir(), "clip")
+	b := CommandBackend{
+		ReadCmd:  []string{"cat", path},
+		WriteCmd: []string{"sh", "-c", "cat > " + path},
+	}
+	if err := b.Write("héllo\nworld"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "héllo\nworld" {
+		t.Errorf("Expected the written text back, got %q", data)
+	}
+}
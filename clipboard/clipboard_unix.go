@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+var backend = &fallbackBackend{
+	primary: defaultBackend(),
+}
+
+// Replace the backend picked for the current platform.
+// The in-process clipboard is still used when it fails
+func SetBackend(b Backend) {
+	backend.primary = b
+}
+
+func readClipboard() (string, error) {
+	return backend.Read()
+}
+
+func writeClipboard(text string) error {
+	return backend.Write(text)
+}
@@ -11,7 +11,7 @@ import (
 
 const (
 	cfUnicodeText = 13
-	// gmemMoveable  = 0x0002
+	gmemMoveable  = 0x0002
 )
 
 var (
@@ -39,11 +39,22 @@ func tryOpenClipboard() error {
 	return err
 }
 
+// The error of a failed call, nil if the call only found nothing.
+// The calls give the last error, "The operation completed
+// successfully" when there is none
+func callError(err error) error {
+	if errno, ok := err.(syscall.Errno); ok && errno == 0 {
+		return nil
+	}
+	return err
+}
+
 func readClipboard() (string, error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
+	// No text in the clipboard isn't a failure
 	if ok, _, err := isClipboardFormatAvailable.Call(cfUnicodeText); ok == 0 {
-		return "", err
+		return "", callError(err)
 	}
 
 	err := tryOpenClipboard()
@@ -53,7 +64,8 @@ func readClipboard() (string, error) {
 
 	clipPtr, _, err := getClipboardData.Call(cfUnicodeText)
 	if clipPtr == 0 {
-		return "", err
+		closeClipboard.Call()
+		return "", callError(err)
 	}
 
 	data, _, err := globalLock.Call(clipPtr)
@@ -65,16 +77,62 @@ func readClipboard() (string, error) {
 	t := (*[1 << 20]uint16)(unsafe.Pointer(data))
 	clipText := syscall.UTF16ToString(t[:])
 
-	ok, _, err := globalUnlock.Call()
+	globalUnlock.Call(clipPtr)
+
+	ok, _, err := closeClipboard.Call()
 	if ok == 0 {
-		closeClipboard.Call()
 		return "", err
 	}
 
-	ok, _, err = closeClipboard.Call()
+	return clipText, nil
+}
+
+func writeClipboard(text string) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	data, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+
+	err = tryOpenClipboard()
+	if err != nil {
+		return err
+	}
+
+	if ok, _, err := emptyClipboard.Call(); ok == 0 {
+		closeClipboard.Call()
+		return err
+	}
+
+	// The memory is owned by the system once given to SetClipboardData
+	size := uintptr(len(data)) * unsafe.Sizeof(data[0])
+	mem, _, err := globalAlloc.Call(gmemMoveable, size)
+	if mem == 0 {
+		closeClipboard.Call()
+		return err
+	}
+
+	dst, _, err := globalLock.Call(mem)
+	if dst == 0 {
+		globalFree.Call(mem)
+		closeClipboard.Call()
+		return err
+	}
+	lstrcpy.Call(dst, uintptr(unsafe.Pointer(&data[0])))
+	globalUnlock.Call(mem)
+
+	if ok, _, err := setClipboardData.Call(cfUnicodeText, mem); ok == 0 {
+		globalFree.Call(mem)
+		closeClipboard.Call()
+		return err
+	}
+
+	ok, _, err := closeClipboard.Call()
 	if ok == 0 {
-		return "", err
+		return err
 	}
 
-	return clipText, nil
+	return nil
 }
@@ -0,0 +1,296 @@
+// Package cmdline reads the commands typed in the command panel,
+// like `:openproject "C:/My Projects/foo"`, and checks their words
+// against the arguments and flags a command expects.
+package cmdline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// The type of an argument or a flag value
+	Kind uint8
+
+	// A positional argument
+	Arg struct {
+		Name string
+		Kind Kind
+		// The values an Enum accepts, matched ignoring the case
+		Values []string
+		// Optional arguments come after the required ones
+		Optional bool
+		// The last argument can take the remaining words, joined by spaces
+		Rest bool
+	}
+
+	// A flag written --name, or --name=value if it isn't a Bool
+	Flag struct {
+		Name   string
+		Kind   Kind
+		Values []string
+	}
+
+	// The arguments and flags of a command
+	Spec struct {
+		Args  []Arg
+		Flags []Flag
+	}
+
+	// The values read by Spec.Parse, by argument or flag name
+	Args struct {
+		values map[string]string
+		ints   map[string]int
+	}
+)
+
+const (
+	String Kind = iota
+	Int
+	Path
+	Enum
+	// A flag without value
+	Bool
+)
+
+// Cut <input> into words separated by spaces. Quotes, " or ', group
+// words, and a backslash escapes a quote or a space. Between double
+// quotes, it escapes a double quote or another backslash. Any other
+// backslash is kept, so Windows paths can be typed as is.
+//
+// On an unterminated quote, the words read so far are returned
+// with the error, the last one holding the text after the quote
+func Split(input string) ([]string, error) {
+	var (
+		words    []string
+		word     strings.Builder
+		inWord   bool
+		quote    rune
+		quotePos int
+	)
+	runes := []rune(input)
+	for i := 0; i < len(runes); i += 1 {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case r == '\\' && i+1 < len(runes) && isEscaped(runes[i+1], quote):
+			i += 1
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			quotePos = i
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if quote != 0 {
+		return words, fmt.Errorf("unterminated quote at column %d", quotePos+1)
+	}
+	return words, nil
+}
+
+// Nothing is escaped between single quotes
+func isEscaped(r rune, quote rune) bool {
+	switch quote {
+	case '\'':
+		return false
+	case '"':
+		return r == '"' || r == '\\'
+	}
+	return r == '"' || r == '\'' || r == ' '
+}
+
+// Write <word> so Split reads it back as a single word
+func Quote(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\"'") {
+		return word
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range word {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Describe the spec, like "[--case] <line> [query...]"
+func (s Spec) Usage() string {
+	var parts []string
+	for _, f := range s.Flags {
+		if f.Kind == Bool {
+			parts = append(parts, "[--"+f.Name+"]")
+		} else {
+			parts = append(parts, "[--"+f.Name+"=<"+f.Name+">]")
+		}
+	}
+	for _, a := range s.Args {
+		name := a.Name
+		if a.Rest {
+			name += "..."
+		}
+		if a.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Check if the command can run without any word
+func (s Spec) NeedsArgs() bool {
+	for _, a := range s.Args {
+		if !a.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// Read <words> as the flags then the arguments of the spec.
+// A "--" word ends the flags
+func (s Spec) Parse(words []string) (Args, error) {
+	args := Args{
+		values: make(map[string]string),
+		ints:   make(map[string]int),
+	}
+	i := 0
+	for ; i < len(words) && strings.HasPrefix(words[i], "--"); i += 1 {
+		if words[i] == "--" {
+			i += 1
+			break
+		}
+		name, value, hasValue := cut(words[i][2:], "=")
+		flag, ok := s.flag(name)
+		if !ok {
+			return Args{}, fmt.Errorf("unknown flag --%s", name)
+		}
+		if _, set := args.values[name]; set {
+			return Args{}, fmt.Errorf("flag --%s given twice", name)
+		}
+		if flag.Kind == Bool {
+			if hasValue {
+				return Args{}, fmt.Errorf("flag --%s takes no value", name)
+			}
+			args.values[name] = "true"
+			continue
+		}
+		if !hasValue {
+			return Args{}, fmt.Errorf("flag --%s needs a value, like --%s=<%s>", name, name, name)
+		}
+		if err := args.set("--"+name, name, flag.Kind, flag.Values, value); err != nil {
+			return Args{}, err
+		}
+	}
+
+	words = words[i:]
+	for j, a := range s.Args {
+		if j >= len(words) {
+			if !a.Optional {
+				return Args{}, fmt.Errorf("missing argument <%s>", a.Name)
+			}
+			break
+		}
+		value := words[j]
+		if a.Rest {
+			value = strings.Join(words[j:], " ")
+			words = words[:j+1]
+		}
+		if err := args.set(a.Name, a.Name, a.Kind, a.Values, value); err != nil {
+			return Args{}, err
+		}
+	}
+	if len(words) > len(s.Args) {
+		if len(s.Args) == 0 {
+			return Args{}, fmt.Errorf("unexpected argument %q, expected none", words[0])
+		}
+		return Args{}, fmt.Errorf("unexpected argument %q, expected %s", words[len(s.Args)], s.Usage())
+	}
+	return args, nil
+}
+
+func (s Spec) flag(name string) (Flag, bool) {
+	for _, f := range s.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Check <value> against <kind> and store it. <label>
+// names the argument or the flag in the errors
+func (a Args) set(label string, name string, kind Kind, values []string, value string) error {
+	switch kind {
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s should be a number, got %q", label, value)
+		}
+		a.ints[name] = n
+	case Path:
+		if value == "" {
+			return fmt.Errorf("%s should be a path, got an empty string", label)
+		}
+		value = filepath.Clean(value)
+	case Enum:
+		found := false
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				value = v
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s should be one of %s, got %q", label, strings.Join(values, ", "), value)
+		}
+	}
+	a.values[name] = value
+	return nil
+}
+
+// Check if the argument or the flag <name> was given
+func (a Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// The value of the argument or the flag <name>, "" if it wasn't given
+func (a Args) String(name string) string {
+	return a.values[name]
+}
+
+// The value of the Int argument or flag <name>, 0 if it wasn't given
+func (a Args) Int(name string) int {
+	return a.ints[name]
+}
+
+// strings.Cut isn't there before go 1.18
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
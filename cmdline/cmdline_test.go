@@ -0,0 +1,146 @@
+package cmdline
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		input string
+		words []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{":save", []string{":save"}},
+		{"  :goto   12  ", []string{":goto", "12"}},
+		{`:openproject "C:/My Projects/foo"`, []string{":openproject", "C:/My Projects/foo"}},
+		{`:openproject 'C:/My Projects/foo'`, []string{":openproject", "C:/My Projects/foo"}},
+		{`:openproject C:/My\ Projects/foo`, []string{":openproject", "C:/My Projects/foo"}},
+		{`:openproject C:\Users\nico\`, []string{":openproject", `C:\Users\nico\`}},
+		{`:search "say \"hi\""`, []string{":search", `say "hi"`}},
+		{`:search 'a \" b'`, []string{":search", `a \" b`}},
+		{`:search "a\\b"`, []string{":search", `a\b`}},
+		{`:search it\'s`, []string{":search", "it's"}},
+		{`:search ""`, []string{":search", ""}},
+		{`:search pre"fix suf"fix`, []string{":search", "prefix suffix"}},
+	}
+	for _, test := range tests {
+		words, err := Split(test.input)
+		if err != nil {
+			t.Errorf("Split(%q) returned an error: %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("Split(%q) = %q, expected %q", test.input, words, test.words)
+		}
+	}
+}
+
+func TestSplitUnterminated(t *testing.T) {
+	words, err := Split(`:openproject "C:/My Pro`)
+	if err == nil {
+		t.Fatal("Split should fail on an unterminated quote")
+	}
+	if !strings.Contains(err.Error(), "column 14") {
+		t.Errorf("the error should give the column of the quote, got %q", err)
+	}
+	expected := []string{":openproject", "C:/My Pro"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("Split returned %q, expected %q", words, expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, word := range []string{"plain", "", "C:/My Projects/foo", `say "hi"`, "it's", `C:\dir\`, `a\"b`} {
+		words, err := Split(":cmd " + Quote(word))
+		if err != nil || len(words) != 2 || words[1] != word {
+			t.Errorf("Quote(%q) = %s, read back as %q (%v)", word, Quote(word), words, err)
+		}
+	}
+	if q := Quote("plain"); q != "plain" {
+		t.Errorf("Quote(%q) = %s, a word without space shouldn't be quoted", "plain", q)
+	}
+}
+
+var testSpec = Spec{
+	Args: []Arg{
+		{Name: "line", Kind: Int},
+		{Name: "ending", Kind: Enum, Values: []string{"LF", "CRLF"}, Optional: true},
+		{Name: "rest", Optional: true, Rest: true},
+	},
+	Flags: []Flag{
+		{Name: "case", Kind: Bool},
+		{Name: "width", Kind: Int},
+	},
+}
+
+func TestParse(t *testing.T) {
+	args, err := testSpec.Parse([]string{"--case", "--width=4", "12", "crlf", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !args.Has("case") || args.Has("missing") {
+		t.Errorf("Has should tell the given flags")
+	}
+	if args.Int("width") != 4 || args.Int("line") != 12 {
+		t.Errorf("got width %d and line %d, expected 4 and 12", args.Int("width"), args.Int("line"))
+	}
+	if args.String("ending") != "CRLF" {
+		t.Errorf("an enum should be read ignoring the case, got %q", args.String("ending"))
+	}
+	if args.String("rest") != "a b" {
+		t.Errorf("the rest argument should join the words, got %q", args.String("rest"))
+	}
+
+	args, err = testSpec.Parse([]string{"--", "7"})
+	if err != nil || args.Int("line") != 7 || args.Has("ending") {
+		t.Errorf("\"--\" should end the flags, got %v", err)
+	}
+
+	path := Spec{Args: []Arg{{Name: "path", Kind: Path}}}
+	args, err = path.Parse([]string{"foo/../bar/"})
+	if err != nil || args.String("path") != filepath.Clean("bar") {
+		t.Errorf("a path should be cleaned, got %q (%v)", args.String("path"), err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		words []string
+		err   string
+	}{
+		{nil, "missing argument <line>"},
+		{[]string{"ten"}, `line should be a number, got "ten"`},
+		{[]string{"1", "CR"}, `ending should be one of LF, CRLF, got "CR"`},
+		{[]string{"--word", "1"}, "unknown flag --word"},
+		{[]string{"--case", "--case", "1"}, "flag --case given twice"},
+		{[]string{"--case=yes", "1"}, "flag --case takes no value"},
+		{[]string{"--width", "1"}, "flag --width needs a value, like --width=<width>"},
+		{[]string{"--width=wide", "1"}, `--width should be a number, got "wide"`},
+	}
+	for _, test := range tests {
+		_, err := testSpec.Parse(test.words)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Parse(%q) returned error %v, expected %q", test.words, err, test.err)
+		}
+	}
+
+	noArgs := Spec{}
+	_, err := noArgs.Parse([]string{"extra"})
+	if err == nil || err.Error() != `unexpected argument "extra", expected none` {
+		t.Errorf("an extra word should be rejected, got %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	expected := "[--case] [--width=<width>] <line> [ending] [rest...]"
+	if u := testSpec.Usage(); u != expected {
+		t.Errorf("Usage() = %q, expected %q", u, expected)
+	}
+	if !testSpec.NeedsArgs() || (Spec{}).NeedsArgs() {
+		t.Errorf("NeedsArgs should be true only with a required argument")
+	}
+}
@@ -0,0 +1,69 @@
+package cmdline
+
+// The commands run from the command panel, the oldest first.
+// It is walked like the history of a shell, up being older
+type History struct {
+	entries []string
+	cap     int
+	// The entry shown, len(entries) when the history isn't walked
+	pos int
+}
+
+func NewHistory(cap int) *History {
+	return &History{cap: cap}
+}
+
+// Replace the entries, keeping the <cap> last ones
+func (h *History) Load(entries []string) {
+	if len(entries) > h.cap {
+		entries = entries[len(entries)-h.cap:]
+	}
+	h.entries = append(h.entries[:0], entries...)
+	h.Reset()
+}
+
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Remember <input> as the last command run. A command
+// run again moves to the end instead of being repeated
+func (h *History) Add(input string) {
+	for i, e := range h.entries {
+		if e == input {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, input)
+	if len(h.entries) > h.cap {
+		h.entries = h.entries[1:]
+	}
+	h.Reset()
+}
+
+// Stop walking, the next move up gives the last entry
+func (h *History) Reset() {
+	h.pos = len(h.entries)
+}
+
+// Check if <input> is the entry the walk stopped at
+func (h *History) Walking(input string) bool {
+	return h.pos < len(h.entries) && h.entries[h.pos] == input
+}
+
+// Move by <n> entries, -1 being the previous (older) one. Moving
+// past the last entry stops the walk and gives "". <ok> is false
+// if there is nothing older
+func (h *History) Move(n int) (entry string, ok bool) {
+	pos := h.pos + n
+	switch {
+	case pos < 0:
+		return "", false
+	case pos >= len(h.entries):
+		h.Reset()
+		return "", true
+	}
+	h.pos = pos
+	return h.entries[pos], true
+}
@@ -0,0 +1,49 @@
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistoryWalk(t *testing.T) {
+	h := NewHistory(10)
+	h.Load([]string{":save", ":goto 12", ":quit"})
+
+	// Up goes to the older entries
+	for _, expected := range []string{":quit", ":goto 12", ":save"} {
+		entry, ok := h.Move(-1)
+		if !ok || entry != expected {
+			t.Fatalf("Expected %q going up, got %q", expected, entry)
+		}
+		if !h.Walking(entry) {
+			t.Errorf("%q should be the entry walked", entry)
+		}
+	}
+	if _, ok := h.Move(-1); ok {
+		t.Errorf("There is nothing older than the first entry")
+	}
+	// And down back to the newer ones, then out of the history
+	if entry, _ := h.Move(1); entry != ":goto 12" {
+		t.Errorf("Expected :goto 12 going down, got %q", entry)
+	}
+	h.Move(1)
+	if entry, ok := h.Move(1); !ok || entry != "" {
+		t.Errorf("Going down from the last entry should leave the history, got %q", entry)
+	}
+	if h.Walking(":quit") {
+		t.Errorf("The history shouldn't be walked anymore")
+	}
+}
+
+func TestHistoryAdd(t *testing.T) {
+	h := NewHistory(3)
+	for _, input := range []string{"a", "b", "c", "a", "d"} {
+		h.Add(input)
+	}
+	if expected := []string{"c", "a", "d"}; !reflect.DeepEqual(h.Entries(), expected) {
+		t.Errorf("Expected %v, got %v", expected, h.Entries())
+	}
+	if entry, _ := h.Move(-1); entry != "d" {
+		t.Errorf("Adding an entry should restart the walk, got %q", entry)
+	}
+}
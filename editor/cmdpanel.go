@@ -1,16 +1,35 @@
 package editor
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nico-ec/uwu/cmdline"
+	"github.com/nico-ec/uwu/fuzzy"
 	"github.com/nico-ec/uwu/ui"
 )
 
+const (
+	cmdPanelMaxResults = 100
+	// The commands run from the panel, one per line, next to the assets
+	cmdHistoryPath = "cmdhistory.txt"
+	cmdHistoryCap  = 100
+)
+
+// The command palette. It lists the commands matching the input,
+// then the values the argument being typed can complete to
 type CmdPanel struct {
-	window  ui.WinHandle
-	textBox *ui.TextBox
+	window     ui.WinHandle
+	textBox    *ui.TextBox
+	resultList *ui.ResultList
+
+	// The input the list was built for
+	input string
+	// The input each entry of the list completes to
+	completions []string
+
+	history *cmdline.History
 }
 
 func (c *CmdPanel) initCmdPanel() {
@@ -20,11 +39,11 @@ func (c *CmdPanel) initCmdPanel() {
 	//
 	c.window = ui.AddWindow(ui.Window{
 		Active: false,
-		Rect:   ui.Rectangle{550, 380, 500, 44},
+		Rect:   ui.Rectangle{X: 450, Y: 150, Width: 700, Height: 300},
 		Style: ui.Style{
 			Ordering: ui.StyleOrderRow,
-			Padding:  0,
-			Margin:   ui.Point{0, 0},
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
 		},
 		Background: ui.Background{
 			Visible: true,
@@ -67,7 +86,9 @@ func (c *CmdPanel) initCmdPanel() {
 
 	c.textBox = &ui.TextBox{
 		Background: ui.Background{
-			Visible: false,
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
 		},
 		Cap:       500,
 		Margin:    3,
@@ -76,68 +97,248 @@ func (c *CmdPanel) initCmdPanel() {
 		TextClr:   theme.normalTextClr,
 		Multiline: false,
 	}
-	c.window.AddWidget(c.textBox, ui.FitContainer)
+	c.resultList = &ui.ResultList{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Margin: ui.Point{3, 3},
+		},
+		Font:     &ed.font,
+		TextSize: 12,
+		TextClr:  theme.normalTextClr,
+		Receiver: c,
+	}
+	c.window.AddWidget(c.textBox, 22)
+	c.window.AddWidget(c.resultList, ui.FitContainer)
 	c.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, c)
+
+	c.loadHistory()
+}
+
+func (c *CmdPanel) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		c.window.SetColors(theme.windowColors())
+		c.textBox.Background.Clr = theme.backgroundClr2
+		c.textBox.TextClr = theme.normalTextClr
+		c.resultList.TextClr = theme.normalTextClr
+	}
 }
 
 func (c *CmdPanel) updateCmdPanel() {
-	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyP) {
-		c.window.SetActive(!c.window.IsActive())
-		c.textBox.SetFocus(true)
+	if !c.window.IsActive() {
+		return
 	}
+
+	if input := string(c.textBox.GetCharBuffer()); input != c.input {
+		c.list(input)
+	}
+}
+
+func (c *CmdPanel) toggle() {
 	if c.window.IsActive() {
-		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-			cmd := c.textBox.GetCharBuffer()
-			c.parseCommand(string(cmd))
-
-			c.textBox.EmptyCharBuffer()
-			c.window.SetActive(false)
-		} else if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-			c.window.SetActive(false)
-		}
+		c.window.SetActive(false)
+		return
 	}
+	c.open(":")
 }
 
-func (c *CmdPanel) parseCommand(input string) {
-	if len(input) == 0 {
-		err := SignalError{
-			Kind: editorWarning,
-			Msg:  "Unknown command",
+// Show the panel with <input> already typed
+func (c *CmdPanel) open(input string) {
+	c.history.Reset()
+	c.setInput(input)
+	c.window.SetActive(true)
+	c.textBox.SetFocus(true)
+}
+
+// Replace the input and move the caret after it
+func (c *CmdPanel) setInput(input string) {
+	runes := []rune(input)
+	c.textBox.LoadBufferData(runes)
+	c.textBox.SetSelection(len(runes), len(runes))
+	c.list(input)
+}
+
+// Fill the list with the completions of <input>: the
+// commands while the name is typed, then the values
+// of the argument being typed
+func (c *CmdPanel) list(input string) {
+	c.input = input
+	c.completions = c.completions[:0]
+	var items []string
+
+	name, args := splitCommand(input)
+	if args == nil {
+		names := commandNames()
+		for _, m := range fuzzy.Rank(name, names, cmdPanelMaxResults) {
+			cmd := commands[names[m.Index]]
+			completion := ":" + cmd.name
+			if cmd.usage() != "" {
+				completion += " "
+			}
+			c.completions = append(c.completions, completion)
+			items = append(items, describeCommand(cmd))
 		}
-		FireSignal(EditorErrorRaised, err)
+	} else if cmd, exist := commands[name]; exist && cmd.complete != nil {
+		// The words before the one typed are kept as they are
+		prefix := ":" + name + " "
+		for _, word := range args[:len(args)-1] {
+			prefix += cmdline.Quote(word) + " "
+		}
+		for _, value := range cmd.complete(args[len(args)-1]) {
+			c.completions = append(c.completions, prefix+cmdline.Quote(value))
+			items = append(items, value)
+		}
+	}
+	c.resultList.SetItems(items)
+}
+
+// Split an input like ":name args..." into the name of the
+// command and its words. <args> is nil while the name is typed,
+// its last word is the one being typed, "" after a space
+func splitCommand(input string) (name string, args []string) {
+	words, err := cmdline.Split(input)
+	if len(words) == 0 {
+		return "", nil
+	}
+	name = strings.TrimPrefix(words[0], ":")
+	args = words[1:]
+	// A space typed inside quotes doesn't start another word
+	if err == nil && strings.HasSuffix(input, " ") && !strings.HasSuffix(input, "\\ ") {
+		args = append(args, "")
+	}
+	if len(args) == 0 {
+		return name, nil
+	}
+	return name, args
+}
+
+// A line of the list: the command, what it does and its keys
+func describeCommand(cmd *command) string {
+	usage := ":" + cmd.name
+	if cmd.usage() != "" {
+		usage += " " + cmd.usage()
+	}
+	line := fmt.Sprintf("%-24s %s", usage, cmd.doc)
+	if keys := ed.keymap.Bindings(cmd.name); len(keys) > 0 {
+		line += " (" + strings.Join(keys, ", ") + ")"
+	}
+	return line
+}
+
+// Replace the input with the selected entry of the list
+func (c *CmdPanel) completeSelected() {
+	c.complete(c.resultList.Selected())
+}
+
+func (c *CmdPanel) complete(index int) {
+	if index < 0 || index >= len(c.completions) {
 		return
 	}
-	tokens := strings.Split(input, " ")
+	c.history.Reset()
+	c.setInput(c.completions[index])
+}
 
-	switch tokens[0] {
-	case ":openproject":
-		if len(tokens) != 2 {
-			err := SignalError{
-				Kind: editorError,
-				Msg:  "Invalid arguments for command ':openproject'",
-			}
-			FireSignal(EditorErrorRaised, err)
-			return
+// Run the input. While the name of the command is typed, the
+// selected command is picked, and only run right away if it
+// doesn't need an argument
+func (c *CmdPanel) run() {
+	if name, args := splitCommand(c.input); args == nil {
+		if _, exist := commands[name]; !exist {
+			c.completeSelected()
+			name, _ = splitCommand(c.input)
 		}
-		FireSignal(EditorProjectOpened, SignalString(tokens[1]))
-	case ":openprojectfile":
-		if len(tokens) != 2 {
-			err := SignalError{
-				Kind: editorError,
-				Msg:  "Invalid arguments for command ':openprojectfile'",
-			}
-			FireSignal(EditorErrorRaised, err)
+		if cmd, exist := commands[name]; exist && !cmd.argsOptional() {
+			c.setInput(":" + name + " ")
 			return
 		}
-	default:
+	}
+
+	input := strings.TrimSpace(c.input)
+	c.addToHistory(input)
+	c.textBox.EmptyCharBuffer()
+	c.window.SetActive(false)
+	c.parseCommand(input)
+}
+
+// Run an input like ":name args..." through the command registry
+func (c *CmdPanel) parseCommand(input string) {
+	words, err := cmdline.Split(input)
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  "Invalid command: " + err.Error(),
+		})
+		return
+	}
+	if len(words) == 0 || !strings.HasPrefix(words[0], ":") {
 		err := SignalError{
 			Kind: editorWarning,
 			Msg:  "Unknown command",
 		}
 		FireSignal(EditorErrorRaised, err)
+		return
+	}
+	runCommand(strings.TrimPrefix(words[0], ":"), words[1:])
+}
+
+// Move the selection of the list. The history is walked instead
+// (up being older) while the input comes from it, when the list is
+// empty, or when going up from its first entry
+func (c *CmdPanel) moveSelection(n int) {
+	walk := c.history.Walking(c.input) || c.resultList.Len() == 0 ||
+		n < 0 && c.resultList.Selected() == 0
+	if !walk {
+		c.resultList.MoveSelection(n)
+		return
+	}
+	input, ok := c.history.Move(n)
+	switch {
+	case !ok:
+	case input == "":
+		c.setInput(":")
+	default:
+		c.setInput(input)
+	}
+}
+
+func (c *CmdPanel) loadHistory() {
+	c.history = cmdline.NewHistory(cmdHistoryCap)
+	data, err := os.ReadFile(cmdHistoryPath)
+	if err != nil {
+		// No command was run yet
+		return
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	c.history.Load(entries)
+}
+
+// Remember <input> as the last command run and save the history
+func (c *CmdPanel) addToHistory(input string) {
+	c.history.Add(input)
+	data := strings.Join(c.history.Entries(), "\n") + "\n"
+	if err := os.WriteFile(cmdHistoryPath, []byte(data), 0644); err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Could not save the command history: " + err.Error(),
+		})
 	}
 }
 
+// A click on an entry completes the input with it
+func (c *CmdPanel) OnResultSelected(index int) {
+	c.complete(index)
+	c.textBox.SetFocus(true)
+}
+
 func (c *CmdPanel) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
 	c.window.SetActive(false)
 }
@@ -0,0 +1,370 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nico-ec/uwu/cmdline"
+	"github.com/nico-ec/uwu/fuzzy"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// A named action, run from the command panel
+// (as ":name args...") or from a keybinding
+type command struct {
+	name string
+	args cmdline.Spec
+	doc  string
+	run  func(args cmdline.Args)
+	// The values the argument being typed can complete to, nil if
+	// the argument can't be completed
+	complete func(arg string) []string
+}
+
+var commands = make(map[string]*command)
+
+// Register a command. The values of an Enum first
+// argument are used to complete it
+func registerCommand(name string, args cmdline.Spec, doc string, run func(args cmdline.Args)) *command {
+	cmd := &command{
+		name: name,
+		args: args,
+		doc:  doc,
+		run:  run,
+	}
+	if len(args.Args) > 0 && args.Args[0].Kind == cmdline.Enum {
+		values := args.Args[0].Values
+		cmd.complete = func(arg string) []string {
+			return completeFrom(values, arg)
+		}
+	}
+	commands[name] = cmd
+	return cmd
+}
+
+// The arguments the command expects, like "<path>"
+func (c *command) usage() string {
+	return c.args.Usage()
+}
+
+// Check if the command can run without any argument
+func (c *command) argsOptional() bool {
+	return !c.args.NeedsArgs()
+}
+
+// Run the command called <name> with the <words> typed after it,
+// report an error if there is none or if the words are invalid
+func runCommand(name string, words []string) {
+	cmd, exist := commands[name]
+	if !exist {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Unknown command '" + name + "'",
+		})
+		return
+	}
+	args, err := cmd.args.Parse(words)
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  fmt.Sprintf("Invalid arguments for ':%s': %s", name, err),
+		})
+		return
+	}
+	cmd.run(args)
+}
+
+// Names of the registered commands, sorted
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run <action> on the TextBox of the active tab, if there is one
+func withActiveTextBox(action func(t *ui.TextBox)) func(args cmdline.Args) {
+	return func(args cmdline.Args) {
+		if textBox, _ := ed.textEd.activeFile(); textBox != nil {
+			action(textBox)
+		}
+	}
+}
+
+// Specs shared by several commands
+var (
+	noArgs  = cmdline.Spec{}
+	pathArg = cmdline.Spec{Args: []cmdline.Arg{{Name: "path", Kind: cmdline.Path}}}
+)
+
+func registerCommands() {
+	registerCommand("quit", noArgs, "Close the editor", func(args cmdline.Args) {
+		ed.closeState = fmt.Errorf("closing editor")
+	})
+	registerCommand("openproject", pathArg, "Open a folder as the project", func(args cmdline.Args) {
+		FireSignal(EditorProjectOpened, SignalString(args.String("path")))
+	}).complete = completeFolders
+	registerCommand("openprojectfile", pathArg, "Open a file of the project", func(args cmdline.Args) {
+		openProjectPath(args.String("path"))
+	}).complete = completeProjectFiles
+
+	var endings []string
+	for e := lineEnding(0); e < lineEndingMax; e += 1 {
+		endings = append(endings, e.String())
+	}
+	registerCommand("lineending", cmdline.Spec{
+		Args: []cmdline.Arg{{Name: "ending", Kind: cmdline.Enum, Values: endings}},
+	}, "Save the active file with LF, CRLF or CR", func(args cmdline.Args) {
+		ending, _ := parseLineEnding(args.String("ending"))
+		ed.textEd.setLineEnding(ending)
+	})
+	// The themes are only known once loaded, so the name is checked when run
+	registerCommand("theme", cmdline.Spec{
+		Args: []cmdline.Arg{{Name: "name"}},
+	}, "Switch to another theme", func(args cmdline.Args) {
+		if name := args.String("name"); !setTheme(name) {
+			FireSignal(EditorErrorRaised, SignalError{
+				Kind: editorError,
+				Msg:  "Unknown theme '" + name + "', expected one of " + strings.Join(themeNames(), ", "),
+			})
+		}
+	}).complete = func(arg string) []string {
+		return completeFrom(themeNames(), arg)
+	}
+
+	// Files
+	registerCommand("save", noArgs, "Save the active file", func(args cmdline.Args) {
+		ed.textEd.saveNode()
+	})
+	registerCommand("settings", cmdline.Spec{
+		Args: []cmdline.Arg{{Name: "file", Kind: cmdline.Enum, Values: []string{"user", "project"}, Optional: true}},
+	}, "Open the user settings, or the ones of the project", func(args cmdline.Args) {
+		openSettings(args.String("file") == "project")
+	})
+	registerCommand("undo", noArgs, "Undo the last edit", withActiveTextBox(func(t *ui.TextBox) {
+		t.Undo()
+	}))
+	registerCommand("redo", noArgs, "Redo the last undone edit", withActiveTextBox(func(t *ui.TextBox) {
+		t.Redo()
+	}))
+	registerCommand("linestart", noArgs, "Move the caret to the start of the line", withActiveTextBox(func(t *ui.TextBox) {
+		t.MoveCursorLineStart()
+		t.ClearSelection()
+	}))
+	registerCommand("lineend", noArgs, "Move the caret to the end of the line", withActiveTextBox(func(t *ui.TextBox) {
+		t.MoveCursorLineEnd()
+		t.ClearSelection()
+	}))
+	registerCommand("goto", cmdline.Spec{
+		Args: []cmdline.Arg{{Name: "position", Optional: true}},
+	}, "Move the caret to a line[:column] of the active file, asked if not given", func(args cmdline.Args) {
+		if !args.Has("position") {
+			ed.cmdPanel.open(":goto ")
+			return
+		}
+		line, col, err := parsePosition(args.String("position"))
+		if err != nil {
+			FireSignal(EditorErrorRaised, SignalError{
+				Kind: editorError,
+				Msg:  "Invalid arguments for ':goto': " + err.Error(),
+			})
+			return
+		}
+		ed.textEd.jumpTo(line, col)
+	})
+	registerCommand("format", noArgs, "Format the active file, with go/format or the command set for its extension", func(args cmdline.Args) {
+		if textBox, file := ed.textEd.activeFile(); file != nil {
+			ed.textEd.formatFile(textBox, file, nil)
+		}
+	})
+	registerCommand("selectlinestart", noArgs, "Select up to the start of the line", withActiveTextBox(func(t *ui.TextBox) {
+		t.MoveCursorLineStart()
+	}))
+	registerCommand("selectlineend", noArgs, "Select up to the end of the line", withActiveTextBox(func(t *ui.TextBox) {
+		t.MoveCursorLineEnd()
+	}))
+
+	// Panels
+	registerCommand("cmdpanel", noArgs, "Show or hide the command panel", func(args cmdline.Args) {
+		ed.cmdPanel.toggle()
+	})
+	registerCommand("find", noArgs, "Show or hide the find panel", func(args cmdline.Args) {
+		ed.findPanel.toggle()
+	})
+	registerCommand("findnext", noArgs, "Select the next match of the find panel", func(args cmdline.Args) {
+		ed.findPanel.findNext()
+	})
+	registerCommand("findprevious", noArgs, "Select the previous match of the find panel", func(args cmdline.Args) {
+		ed.findPanel.findPrevious()
+	})
+	registerCommand("search", cmdline.Spec{
+		Args: []cmdline.Arg{{Name: "query", Optional: true, Rest: true}},
+		Flags: []cmdline.Flag{
+			{Name: "case", Kind: cmdline.Bool},
+			{Name: "word", Kind: cmdline.Bool},
+			{Name: "regex", Kind: cmdline.Bool},
+		},
+	}, "Search in the project files", func(args cmdline.Args) {
+		if !args.Has("query") {
+			ed.searchPanel.toggle()
+			return
+		}
+		ed.searchPanel.searchFor(args.String("query"), findOptions{
+			caseSensitive: args.Has("case"),
+			wholeWord:     args.Has("word"),
+			regex:         args.Has("regex"),
+		})
+	})
+	registerCommand("quickopen", noArgs, "Show or hide the quick open window", func(args cmdline.Args) {
+		ed.quickOpen.toggle()
+	})
+	registerCommand("outline", noArgs, "Show or hide the declarations of the active Go file", func(args cmdline.Args) {
+		ed.outline.toggle(false)
+	})
+	registerCommand("symbol", noArgs, "Go to a declaration of the active Go file", func(args cmdline.Args) {
+		ed.outline.toggle(true)
+	})
+	registerCommand("autocomplete", noArgs, "Show the completions of the word before the caret", withActiveTextBox(func(t *ui.TextBox) {
+		if c := t.Completion(); c != nil {
+			c.Open()
+		}
+	}))
+	registerCommand("hover", noArgs, "Describe the symbol under the caret, from the language server", func(args cmdline.Args) {
+		ed.lsp.hover()
+	})
+	registerCommand("definition", noArgs, "Go to the definition of the symbol under the caret", func(args cmdline.Args) {
+		ed.lsp.definition()
+	})
+	registerCommand("problems", noArgs, "Show or hide the diagnostics of the project", func(args cmdline.Args) {
+		ed.problems.toggle()
+	})
+	registerCommand("closepanel", noArgs, "Close the focused panel", func(args cmdline.Args) {
+		switch ed.keyContext() {
+		case cmdPanelContext:
+			ed.cmdPanel.window.SetActive(false)
+		case findContext:
+			ed.findPanel.close()
+		case searchContext:
+			ed.searchPanel.close()
+		case quickOpenContext:
+			ed.quickOpen.window.SetActive(false)
+		case outlineContext:
+			ed.outline.window.SetActive(false)
+		case problemsContext:
+			ed.problems.window.SetActive(false)
+		case completionContext:
+			if textBox, _ := ed.textEd.activeFile(); textBox != nil {
+				textBox.Completion().Close()
+			}
+		}
+	})
+	registerCommand("accept", noArgs, "Run the command or open the selected result of the focused panel", func(args cmdline.Args) {
+		switch ed.keyContext() {
+		case cmdPanelContext:
+			ed.cmdPanel.run()
+		case searchContext:
+			ed.searchPanel.accept()
+		case quickOpenContext:
+			ed.quickOpen.resultList.Activate()
+		case outlineContext:
+			ed.outline.resultList.Activate()
+		case problemsContext:
+			ed.problems.resultList.Activate()
+		}
+	})
+	registerCommand("selectnext", noArgs, "Select the next result of the focused panel", func(args cmdline.Args) {
+		if ed.keyContext() == cmdPanelContext {
+			ed.cmdPanel.moveSelection(1)
+		} else if list := ed.focusedResultList(); list != nil {
+			list.MoveSelection(1)
+		}
+	})
+	registerCommand("selectprevious", noArgs, "Select the previous result of the focused panel", func(args cmdline.Args) {
+		if ed.keyContext() == cmdPanelContext {
+			ed.cmdPanel.moveSelection(-1)
+		} else if list := ed.focusedResultList(); list != nil {
+			list.MoveSelection(-1)
+		}
+	})
+	registerCommand("complete", noArgs, "Complete the input of the command panel", func(args cmdline.Args) {
+		ed.cmdPanel.completeSelected()
+	})
+}
+
+// Read a position like "12" or "12:5", the column
+// being counted like in the status bar
+func parsePosition(s string) (line int, col int, err error) {
+	lineStr, colStr := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		lineStr, colStr = s[:i], s[i+1:]
+	}
+	if line, err = strconv.Atoi(lineStr); err != nil {
+		return 0, 0, fmt.Errorf("line should be a number, got %q", lineStr)
+	}
+	if colStr != "" {
+		if col, err = strconv.Atoi(colStr); err != nil {
+			return 0, 0, fmt.Errorf("column should be a number, got %q", colStr)
+		}
+	}
+	return line, col, nil
+}
+
+// The <values> starting with <arg>, ignoring the case
+func completeFrom(values []string, arg string) []string {
+	var result []string
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToLower(v), strings.ToLower(arg)) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// The folders whose path starts with <arg>. The hidden
+// ones are only listed once their '.' is typed
+func completeFolders(arg string) []string {
+	dir, base := filepath.Split(arg)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(base)) {
+			result = append(result, dir+name+"/")
+		}
+	}
+	return result
+}
+
+// The project files best matching <arg>
+func completeProjectFiles(arg string) []string {
+	if ed.project.root == nil {
+		return nil
+	}
+	files := ed.project.files()
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = ed.project.relativePath(f.path())
+	}
+	matches := fuzzy.Rank(arg, paths, cmdPanelMaxResults)
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = paths[m.Index]
+	}
+	return result
+}
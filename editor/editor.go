@@ -3,10 +3,15 @@ package editor
 import (
 	"fmt"
 	"image"
+	"io/fs"
+	"os"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/nico-ec/uwu/keymap"
+	"github.com/nico-ec/uwu/syntax"
 	"github.com/nico-ec/uwu/ui"
 )
 
@@ -15,6 +20,11 @@ const (
 	EditorColumnChanged
 	EditorProjectOpened
 	EditorErrorRaised
+	EditorLineEndingChanged
+	EditorSearchMatchesChanged
+	EditorThemeChanged
+	EditorSettingsChanged
+	EditorDiagnosticsChanged
 )
 
 const (
@@ -31,6 +41,9 @@ const (
 	editorFatalError
 )
 
+// The language definitions, next to the assets
+const languagesDir = "languages"
+
 var ed *Editor
 
 type Editor struct {
@@ -50,18 +63,24 @@ type Editor struct {
 	file    Image
 	theme   theme
 
-	window   ui.WinHandle
-	treeView treeview
-	textEd   textEditor
-	cmdPanel CmdPanel
+	settings settings
+	keymap   *keymap.Keymap
+
+	window      ui.WinHandle
+	treeView    treeview
+	textEd      textEditor
+	cmdPanel    CmdPanel
+	findPanel   FindPanel
+	searchPanel SearchPanel
+	quickOpen   QuickOpen
+	outline     OutlinePanel
+	lsp         languageServers
+	problems    ProblemsPanel
 
 	statusbar statusBar
 }
 
 func (ed *Editor) Update() error {
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		ed.closeState = fmt.Errorf("closing editor")
-	}
 	if ed.closeState == nil {
 		var runes []rune
 		runes = ebiten.AppendInputChars(runes[:0])
@@ -71,22 +90,37 @@ func (ed *Editor) Update() error {
 		}
 		mx, my := ebiten.CursorPosition()
 		mleft := ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+		wx, wy := ebiten.Wheel()
 		ed.ctx.UpdateUI(ui.Input{
 			MPos:  ui.Point{float64(mx), float64(my)},
 			MLeft: mleft,
+			Wheel: ui.Point{wx, wy},
 			Enter: ebiten.IsKeyPressed(ebiten.KeyEnter) || ebiten.IsKeyPressed(ebiten.KeyKPEnter),
 			Del:   ebiten.IsKeyPressed(ebiten.KeyBackspace),
 			Ctrl:  ebiten.IsKeyPressed(ebiten.KeyControlLeft) || ebiten.IsKeyPressed(ebiten.KeyControlRight),
+			Shift: ebiten.IsKeyPressed(ebiten.KeyShift),
 			Tab:   ebiten.IsKeyPressed(ebiten.KeyTab),
 			Left:  ebiten.IsKeyPressed(ebiten.KeyLeft),
 			Right: ebiten.IsKeyPressed(ebiten.KeyRight),
 			Up:    ebiten.IsKeyPressed(ebiten.KeyUp),
 			Down:  ebiten.IsKeyPressed(ebiten.KeyDown),
+			Copy:  ebiten.IsKeyPressed(ebiten.KeyControl) && ebiten.IsKeyPressed(ebiten.KeyC),
+			Cut:   ebiten.IsKeyPressed(ebiten.KeyControl) && ebiten.IsKeyPressed(ebiten.KeyX),
 			Paste: ebiten.IsKeyPressed(ebiten.KeyControl) && ebiten.IsKeyPressed(ebiten.KeyV),
+
+			PageUp:   ebiten.IsKeyPressed(ebiten.KeyPageUp),
+			PageDown: ebiten.IsKeyPressed(ebiten.KeyPageDown),
 		})
 
+		ed.updateKeys()
 		ed.textEd.updateTextEditor()
+		ed.findPanel.updateFindPanel()
+		ed.searchPanel.updateSearchPanel()
 		ed.cmdPanel.updateCmdPanel()
+		ed.quickOpen.updateQuickOpen()
+		ed.outline.updateOutlinePanel()
+		ed.lsp.update()
+		ed.problems.updateProblemsPanel()
 		ed.statusbar.updateStatusBar()
 	}
 	return ed.closeState
@@ -247,7 +281,7 @@ func (ed *Editor) Draw(screen *ebiten.Image) {
 }
 
 func (e *Editor) Layout(w, h int) (int, int) {
-	return 1600, 900
+	return e.settings.windowWidth, e.settings.windowHeight
 }
 
 func NewEditor() *Editor {
@@ -256,9 +290,16 @@ func NewEditor() *Editor {
 	ed.signals.init()
 	ed.ctx.SetCursorShapeCallback(changeEditorCursorShape)
 	ui.MakeContextCurrent(ed.ctx)
-	ed.font = NewFont("assets/CozetteVector.ttf", 72, []int{12})
 
-	setTheme(lightTheme)
+	// Like the themes, the errors are reported once the statusbar exists
+	settingsErrs := ed.loadStartupSettings()
+	// The UI is drawn at 12, the text of the files at the size of the settings
+	ed.font = NewFont(ed.settings.fontPath, 72, []int{12, ed.settings.fontSize})
+
+	// The widgets are built with the theme colors, the
+	// errors are reported once the statusbar exists
+	themeErrs := loadThemes(themesDir)
+	setTheme(defaultThemeName)
 
 	// TODO: refactor this into a function
 	i, _, err := ebitenutil.NewImageFromFile("assets/uiHeader.png")
@@ -318,11 +359,16 @@ func NewEditor() *Editor {
 	}
 
 	ed.signals.addListener(EditorProjectOpened, ed)
+	ed.signals.addListener(EditorThemeChanged, ed)
 
 	ed.window = ui.AddWindow(
 		ui.Window{
-			Active: true,
-			Rect:   ui.Rectangle{0, 0, 1600, 900},
+			Active:   true,
+			Backdrop: true,
+			Rect: ui.Rectangle{
+				Width:  float64(ed.settings.windowWidth),
+				Height: float64(ed.settings.windowHeight),
+			},
 			Style: ui.Style{
 				Ordering: ui.StyleOrderRow,
 				Padding:  0,
@@ -418,12 +464,72 @@ func NewEditor() *Editor {
 	ed.statusbar = newStatusBar(ed.window, &ed.font)
 	ed.statusbar.initStatusBar()
 
+	reportSettingsErrors(settingsErrs)
+	for _, err := range themeErrs {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Could not load theme " + err.Error(),
+		})
+	}
+
+	// Syntax highlighting rules, a file with errors is skipped
+	for _, err := range syntax.LoadDir(languagesDir) {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Could not load language " + err.Error(),
+		})
+	}
+
 	// cmd panel
 	ed.cmdPanel.initCmdPanel()
 
+	// find panel
+	ed.findPanel.initFindPanel()
+
+	// project search panel
+	ed.searchPanel.initSearchPanel()
+
+	// quick open
+	ed.quickOpen.initQuickOpen()
+
+	// outline of the active file
+	ed.outline.initOutlinePanel()
+
+	// language servers of the project
+	ed.lsp.init()
+
+	// diagnostics of the project
+	ed.problems.initProblemsPanel()
+
+	// Keybindings, checked against the registered commands
+	registerCommands()
+	var keymapErrs []error
+	ed.keymap, keymapErrs = loadKeymap()
+	for _, err := range keymapErrs {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Invalid keybinding in " + keymapPath + ": " + err.Error(),
+		})
+	}
+
+	// The widgets follow the theme changes
+	AddSignalListener(EditorThemeChanged, &ed.treeView)
+	AddSignalListener(EditorThemeChanged, &ed.textEd)
+	AddSignalListener(EditorSettingsChanged, &ed.textEd)
+
 	return ed
 }
 
+// Load the settings before anything is built,
+// and set up the ebiten window with them
+func (e *Editor) loadStartupSettings() []error {
+	var errs []error
+	e.settings, errs = loadSettings("")
+	ebiten.SetWindowSize(e.settings.windowWidth, e.settings.windowHeight)
+	ebiten.SetMaxTPS(e.settings.tps)
+	return errs
+}
+
 func (e *Editor) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
 	switch id {
 	case editorMinimizeBtn:
@@ -439,6 +545,10 @@ func (e *Editor) OnSignal(s Signal) {
 		path := string(s.Value.(SignalString))
 		ed.project = openProject(path)
 		ed.treeView.loadProject(&ed.project)
+		// The project may have its own settings
+		reloadSettings()
+	case EditorThemeChanged:
+		ed.window.SetColors(ed.theme.windowColors())
 	}
 }
 
@@ -447,6 +557,74 @@ func openProjectFile(name string) {
 	ed.textEd.loadNode(node)
 }
 
+// Open a file from its path rather than its name,
+// since names aren't unique across folders
+func openProjectPath(path string) bool {
+	if ed.project.root == nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No project opened",
+		})
+		return false
+	}
+	node, ok := ed.project.findNodeByPath(path).(file)
+	if !ok {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  "No file at " + path + " in the project",
+		})
+		return false
+	}
+	return ed.textEd.loadNode(node)
+}
+
+// Give the keys back to the active file, from a panel left open
+// while editing. A click on the panel gives it the focus again
+func focusEditor() {
+	ed.window.FocusWindow()
+	if textBox, _ := ed.textEd.activeFile(); textBox != nil {
+		textBox.SetFocus(true)
+	}
+}
+
+// Open a file that may be outside of the project, like the user settings
+func openFilePath(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  "Could not open " + path + ": " + err.Error(),
+		})
+		return false
+	}
+	return ed.textEd.loadNode(file{
+		entry:    fs.FileInfoToDirEntry(info),
+		nodePath: path,
+	})
+}
+
+// Open the user settings, or the ones of the project with <project>
+func openSettings(project bool) {
+	if !project {
+		openFilePath(userSettingsPath)
+		return
+	}
+	if ed.project.root == nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No project opened",
+		})
+		return
+	}
+	path := ed.project.root.nodePath + "/" + projectSettingsName
+	// The same node as the tree, so the file isn't opened twice
+	if node, ok := ed.project.findNodeByPath(path).(file); ok {
+		ed.textEd.loadNode(node)
+		return
+	}
+	openFilePath(path)
+}
+
 func changeEditorCursorShape(s ui.CursorShape) {
 	var ebitenCursorShape ebiten.CursorShapeType
 	switch s {
@@ -458,9 +636,16 @@ func changeEditorCursorShape(s ui.CursorShape) {
 	ebiten.SetCursorShape(ebitenCursorShape)
 }
 
-func setTheme(t theme) {
-	// TODO: Add signal
+// Switch to one of the loaded themes, the widgets
+// apply its colors on EditorThemeChanged
+func setTheme(name string) bool {
+	t, exist := themes[strings.ToLower(name)]
+	if !exist {
+		return false
+	}
 	ed.theme = t
+	FireSignal(EditorThemeChanged, SignalString(name))
+	return true
 }
 
 func getTheme() theme {
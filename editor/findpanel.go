@@ -0,0 +1,454 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/nico-ec/uwu/ui"
+)
+
+const (
+	findPanelRowHeight = 22
+	findMaxMatches     = 10000
+)
+
+const (
+	findCloseBtn ui.ButtonID = iota
+	findCaseBtn
+	findWordBtn
+	findRegexBtn
+	findPreviousBtn
+	findNextBtn
+	findReplaceBtn
+	findReplaceAllBtn
+)
+
+type (
+	FindPanel struct {
+		window     ui.WinHandle
+		findBox    *ui.TextBox
+		replaceBox *ui.TextBox
+		caseBtn    *ui.Button
+		wordBtn    *ui.Button
+		regexBtn   *ui.Button
+		// All the buttons, restyled when the theme changes
+		buttons []*ui.Button
+
+		options findOptions
+		target  *ui.TextBox
+		// The state of the last search, to know when to run it again
+		lastQuery    string
+		lastOptions  findOptions
+		lastRevision int
+
+		// The result of the last search. <locs> are the byte offsets
+		// returned by the regexp, used to expand the replacement
+		re      *regexp.Regexp
+		text    string
+		locs    [][]int
+		matches []ui.TextRange
+	}
+
+	findOptions struct {
+		caseSensitive bool
+		wholeWord     bool
+		regex         bool
+	}
+)
+
+func (f *FindPanel) initFindPanel() {
+	theme := getTheme()
+	f.window = ui.AddWindow(ui.Window{
+		Active: false,
+		Rect:   ui.Rectangle{X: 1050, Y: 55, Width: 520, Height: 20 + findPanelRowHeight*2 + 6},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderRow,
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
+		},
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr1,
+		},
+		HasHeader:    true,
+		HeaderHeight: 20,
+		HeaderBackground: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundImageSlice,
+			Clr:     theme.dividerClr,
+			Img:     &ed.header,
+			Constr:  ui.Constraint{Left: 2, Right: 2, Up: 2, Down: 2},
+		},
+		HasHeaderTitle: true,
+		HeaderTitle:    "Find",
+		HeaderFont:     &ed.font,
+		HeaderFontSize: 12,
+		HeaderFontClr:  theme.normalTextClr,
+
+		HasBorders:  true,
+		BorderWidth: 1,
+		BorderColor: theme.dividerClr,
+	})
+	f.window.SetCloseBtn(ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       findCloseBtn,
+		Clr:          theme.backgroundClr3,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasIcon:      true,
+		Icon:         &ed.cross,
+		IconClr:      theme.backgroundClr1,
+		Receiver:     f,
+	})
+
+	findRow := newFindPanelRow()
+	f.window.AddWidget(findRow, findPanelRowHeight)
+	f.findBox = newFindPanelTextBox()
+	findRow.AddWidget(f.findBox, 300)
+	findRow.AddWidget(f.newButton(findPreviousBtn, "<"), 24)
+	findRow.AddWidget(f.newButton(findNextBtn, ">"), 24)
+	f.caseBtn = f.newButton(findCaseBtn, "Aa")
+	findRow.AddWidget(f.caseBtn, 44)
+	f.wordBtn = f.newButton(findWordBtn, "W")
+	findRow.AddWidget(f.wordBtn, 44)
+	f.regexBtn = f.newButton(findRegexBtn, ".*")
+	findRow.AddWidget(f.regexBtn, ui.FitContainer)
+
+	replaceRow := newFindPanelRow()
+	f.window.AddWidget(replaceRow, findPanelRowHeight)
+	f.replaceBox = newFindPanelTextBox()
+	replaceRow.AddWidget(f.replaceBox, 300)
+	replaceRow.AddWidget(f.newButton(findReplaceBtn, "Replace"), 100)
+	replaceRow.AddWidget(f.newButton(findReplaceAllBtn, "All"), ui.FitContainer)
+
+	f.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, f)
+}
+
+func newFindPanelRow() *ui.Layout {
+	return &ui.Layout{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderColumn,
+			Padding:  2,
+			Margin:   ui.Point{0, 0},
+		},
+	}
+}
+
+func newFindPanelTextBox() *ui.TextBox {
+	theme := getTheme()
+	return &ui.TextBox{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Cap:       200,
+		Margin:    3,
+		Font:      &ed.font,
+		TextSize:  12,
+		TextClr:   theme.normalTextClr,
+		Multiline: false,
+	}
+}
+
+func (f *FindPanel) newButton(id ui.ButtonID, text string) *ui.Button {
+	theme := getTheme()
+	btn := &ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       id,
+		Clr:          theme.backgroundClr2,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasText:      true,
+		Font:         &ed.font,
+		Text:         text,
+		TextClr:      theme.normalTextClr,
+		TextSize:     12,
+		Receiver:     f,
+	}
+	f.buttons = append(f.buttons, btn)
+	return btn
+}
+
+func (f *FindPanel) updateFindPanel() {
+	if !f.window.IsActive() {
+		return
+	}
+
+	textBox, _ := ed.textEd.activeFile()
+	if textBox != f.target {
+		f.setTarget(textBox)
+	}
+	f.search()
+}
+
+// Search in <textBox> from now on. The matches of
+// the previous target are dropped, they are not its offsets
+func (f *FindPanel) setTarget(textBox *ui.TextBox) {
+	if f.target != nil {
+		f.target.SetHighlights(nil)
+	}
+	f.target = textBox
+	f.lastRevision = -1
+	f.re = nil
+	f.locs = nil
+	f.matches = nil
+	if textBox == nil {
+		FireSignal(EditorSearchMatchesChanged, SignalArray{})
+	}
+}
+
+func (f *FindPanel) toggle() {
+	if f.window.IsActive() {
+		f.close()
+	} else {
+		f.open()
+	}
+}
+
+func (f *FindPanel) open() {
+	f.window.SetActive(true)
+	f.findBox.SetFocus(true)
+	f.lastRevision = -1
+}
+
+func (f *FindPanel) close() {
+	f.setTarget(nil)
+	f.window.SetActive(false)
+}
+
+// Run the search again if the query, the options
+// or the searched text changed since the last time
+func (f *FindPanel) search() {
+	query := string(f.findBox.GetCharBuffer())
+	if f.target == nil {
+		return
+	}
+	if query == f.lastQuery && f.options == f.lastOptions && f.target.Revision() == f.lastRevision {
+		return
+	}
+	f.lastQuery = query
+	f.lastOptions = f.options
+	f.lastRevision = f.target.Revision()
+	f.re = nil
+	f.locs = nil
+	f.matches = nil
+
+	if query == "" {
+		f.target.SetHighlights(nil)
+		FireSignal(EditorSearchMatchesChanged, SignalArray{})
+		return
+	}
+	re, err := compileFindPattern(query, f.options)
+	if err != nil {
+		f.target.SetHighlights(nil)
+		FireSignal(EditorSearchMatchesChanged, SignalArray{SignalInt(0), SignalInt(-1)})
+		return
+	}
+	f.re = re
+	f.text = string(f.target.GetCharBuffer())
+	f.locs = re.FindAllStringSubmatchIndex(f.text, findMaxMatches)
+	f.matches = runeRanges(f.text, f.locs)
+	f.target.SetHighlights(f.matches)
+	f.fireMatchCount()
+}
+
+func compileFindPattern(query string, opt findOptions) (*regexp.Regexp, error) {
+	pattern := query
+	if !opt.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opt.wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !opt.caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Convert the byte offsets of the matches to rune offsets in the TextBox.
+// Empty matches are kept in <locs> but can't be highlighted
+func runeRanges(text string, locs [][]int) []ui.TextRange {
+	ranges := make([]ui.TextRange, len(locs))
+	bytePos, runePos := 0, 0
+	for i, loc := range locs {
+		runePos += utf8.RuneCountInString(text[bytePos:loc[0]])
+		start := runePos
+		runePos += utf8.RuneCountInString(text[loc[0]:loc[1]])
+		bytePos = loc[1]
+		ranges[i] = ui.TextRange{Start: start, End: runePos}
+	}
+	return ranges
+}
+
+// Index of the match currently selected in the target, -1 if none
+func (f *FindPanel) currentMatch() int {
+	if f.target == nil || !f.target.HasSelection() {
+		return -1
+	}
+	start, end := f.target.Selection()
+	for i, m := range f.matches {
+		if m.Start == start && m.End == end {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *FindPanel) fireMatchCount() {
+	FireSignal(EditorSearchMatchesChanged, SignalArray{
+		SignalInt(f.currentMatch() + 1),
+		SignalInt(len(f.matches)),
+	})
+}
+
+func (f *FindPanel) selectMatch(i int) {
+	m := f.matches[i]
+	f.target.SetSelection(m.Start, m.End)
+	f.fireMatchCount()
+}
+
+func (f *FindPanel) findNext() {
+	if f.target == nil || len(f.matches) == 0 {
+		return
+	}
+	_, from := f.target.Selection()
+	for i, m := range f.matches {
+		if m.Start >= from && m.End > m.Start {
+			f.selectMatch(i)
+			return
+		}
+	}
+	// Wrap around
+	f.selectMatch(0)
+}
+
+func (f *FindPanel) findPrevious() {
+	if f.target == nil || len(f.matches) == 0 {
+		return
+	}
+	from, _ := f.target.Selection()
+	for i := len(f.matches) - 1; i >= 0; i -= 1 {
+		if m := f.matches[i]; m.End <= from && m.End > m.Start {
+			f.selectMatch(i)
+			return
+		}
+	}
+	f.selectMatch(len(f.matches) - 1)
+}
+
+func (f *FindPanel) replacement(i int) []rune {
+	repl := string(f.replaceBox.GetCharBuffer())
+	if f.options.regex {
+		return []rune(string(f.re.ExpandString(nil, repl, f.text, f.locs[i])))
+	}
+	return []rune(repl)
+}
+
+// Replace the selected match then move to the next one
+func (f *FindPanel) replaceCurrent() {
+	if f.target == nil {
+		return
+	}
+	if i := f.currentMatch(); i >= 0 {
+		f.target.InsertSlice(f.replacement(i))
+		f.search()
+	}
+	f.findNext()
+}
+
+// Replace all the matches as a single undo step
+func (f *FindPanel) replaceAll() {
+	if f.target == nil || len(f.matches) == 0 {
+		return
+	}
+	count := len(f.matches)
+	f.target.BeginTransaction()
+	for i := len(f.matches) - 1; i >= 0; i -= 1 {
+		m := f.matches[i]
+		f.target.SetSelection(m.Start, m.End)
+		f.target.InsertSlice(f.replacement(i))
+	}
+	f.target.EndTransaction()
+	f.search()
+	FireSignal(EditorErrorRaised, SignalError{
+		Kind: editorDebug,
+		Msg:  fmt.Sprintf("Replaced %d occurrences", count),
+	})
+}
+
+func (f *FindPanel) toggleOption(btn *ui.Button, option *bool) {
+	*option = !*option
+	styleOptionButton(btn, *option)
+}
+
+// An enabled option is drawn like a pressed button
+func styleOptionButton(btn *ui.Button, enabled bool) {
+	theme := getTheme()
+	if enabled {
+		btn.Clr = theme.backgroundClr3
+		btn.TextClr = theme.normalTextClr2
+	} else {
+		btn.Clr = theme.backgroundClr2
+		btn.TextClr = theme.normalTextClr
+	}
+}
+
+func (f *FindPanel) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		f.window.SetColors(theme.windowColors())
+		for _, box := range []*ui.TextBox{f.findBox, f.replaceBox} {
+			box.Background.Clr = theme.backgroundClr2
+			box.TextClr = theme.normalTextClr
+		}
+		for _, btn := range f.buttons {
+			btn.Clr = theme.backgroundClr2
+			btn.HighlightClr = theme.backgroundClr3
+			btn.PressedClr = theme.backgroundClr3
+			btn.TextClr = theme.normalTextClr
+		}
+		styleOptionButton(f.caseBtn, f.options.caseSensitive)
+		styleOptionButton(f.wordBtn, f.options.wholeWord)
+		styleOptionButton(f.regexBtn, f.options.regex)
+	}
+}
+
+func (f *FindPanel) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
+	switch id {
+	case findCloseBtn:
+		f.close()
+	case findCaseBtn:
+		f.toggleOption(f.caseBtn, &f.options.caseSensitive)
+	case findWordBtn:
+		f.toggleOption(f.wordBtn, &f.options.wholeWord)
+	case findRegexBtn:
+		f.toggleOption(f.regexBtn, &f.options.regex)
+	case findPreviousBtn:
+		f.findPrevious()
+	case findNextBtn:
+		f.findNext()
+	case findReplaceBtn:
+		if f.target != nil {
+			f.replaceCurrent()
+		}
+	case findReplaceAllBtn:
+		if f.target != nil {
+			f.replaceAll()
+		}
+	}
+}
@@ -11,36 +11,48 @@ import (
 
 type Font struct {
 	faces map[int]font.Face
+	// Kept to add sizes after the font is loaded
+	data *opentype.Font
+	dpi  float64
 }
 
 func NewFont(path string, dpi float64, sizes []int) Font {
 	f := Font{
 		faces: make(map[int]font.Face, len(sizes)),
+		dpi:   dpi,
 	}
 
 	fontData, err := os.ReadFile(path)
 	if err != nil {
 		panic(err)
 	}
-	tt, err := opentype.Parse(fontData)
+	f.data, err = opentype.Parse(fontData)
 	if err != nil {
 		panic(err)
 	}
 
 	for _, v := range sizes {
-		face, err := opentype.NewFace(tt, &opentype.FaceOptions{
-			Size:    float64(v),
-			DPI:     dpi,
-			Hinting: font.HintingNone,
-		})
-		if err != nil {
-			panic(err)
-		}
-		f.faces[v] = face
+		f.addSize(v)
 	}
 	return f
 }
 
+// Make the font usable at <size>, if it wasn't already
+func (f *Font) addSize(size int) {
+	if _, exist := f.faces[size]; exist {
+		return
+	}
+	face, err := opentype.NewFace(f.data, &opentype.FaceOptions{
+		Size:    float64(size),
+		DPI:     f.dpi,
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		panic(err)
+	}
+	f.faces[size] = face
+}
+
 func (f *Font) GlyphAdvance(r rune, size float64) float64 {
 	x, _ := f.faces[int(size)].GlyphAdvance(r)
 	return float64(x>>6) + float64(x&((1<<6)-1))/float64(1<<6)
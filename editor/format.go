@@ -0,0 +1,123 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nico-ec/uwu/cmdline"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// A formatter still running by then is considered stuck
+const formatTimeout = 10 * time.Second
+
+// Format the file edited by <textBox> with the formatter of its
+// extension, as a single undo step, then run <done> if not nil.
+// A failure is reported and leaves the file untouched. The commands
+// run on their own goroutine, so a slow one doesn't stop the editor
+func (t *textEditor) formatFile(textBox *ui.TextBox, file *editedFile, done func()) {
+	finish := func(formatted string, err error) {
+		t.applyFormat(textBox, file, formatted, err)
+		if done != nil {
+			done()
+		}
+	}
+	path := file.node.path()
+	src := string(textBox.GetCharBuffer())
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	command := strings.TrimSpace(ed.settings.formatters[ext])
+	switch {
+	// The result of the command running will be saved too
+	case command != "" && t.formatting[textBox]:
+		if done != nil {
+			done()
+		}
+	case command != "":
+		t.formatting[textBox] = true
+		revision := textBox.Revision()
+		dir := ""
+		if ed.project.root != nil {
+			dir = ed.project.root.nodePath
+		}
+		go func() {
+			formatted, err := runFormatter(command, src, dir)
+			t.formatResults <- func() {
+				delete(t.formatting, textBox)
+				// Typed while it ran, the result is out of date
+				if err == nil && textBox.Revision() != revision {
+					err = fmt.Errorf("the file changed while it was formatted")
+				}
+				finish(formatted, err)
+			}
+		}()
+	// go/format is quick enough to run right away
+	case ext == "go":
+		out, err := format.Source([]byte(src))
+		finish(string(out), err)
+	default:
+		if done != nil {
+			done()
+		}
+	}
+}
+
+func (t *textEditor) applyFormat(textBox *ui.TextBox, file *editedFile, formatted string, err error) {
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  fmt.Sprintf("Could not format %s: %s", file.node.name(), err),
+		})
+		return
+	}
+	textBox.ReplaceBufferData([]rune(formatted))
+}
+
+// Run the formatters that finished
+func (t *textEditor) updateFormatters() {
+	for {
+		select {
+		case result := <-t.formatResults:
+			result()
+		default:
+			return
+		}
+	}
+}
+
+// Run the formatter <command> in <dir>, with <src> on
+// its stdin, and return what it wrote on its stdout
+func runFormatter(command string, src string, dir string) (string, error) {
+	args, err := cmdline.Split(command)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), formatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(src)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("%s still running after %s", args[0], formatTimeout)
+		}
+		// The first line usually tells what is wrong
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", args[0], strings.SplitN(msg, "\n", 2)[0])
+		}
+		return "", err
+	}
+	// Nothing written is more likely a failure than an empty file
+	if stdout.Len() == 0 && strings.TrimSpace(src) != "" {
+		return "", fmt.Errorf("%s wrote nothing", args[0])
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,211 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nico-ec/uwu/keymap"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// The user keybindings, next to the assets
+const keymapPath = "keymap.toml"
+
+// The contexts a key can be bound in
+const (
+	globalContext    = "global"
+	editorContext    = "editor"
+	treeviewContext  = "treeview"
+	cmdPanelContext  = "cmdpanel"
+	findContext      = "find"
+	searchContext    = "search"
+	quickOpenContext = "quickopen"
+	outlineContext   = "outline"
+	problemsContext  = "problems"
+	// The completion popup of the active file
+	completionContext = "completion"
+)
+
+// The built-in bindings, a binding of the user
+// file using the same keys replaces them
+const defaultKeymap = `
+[global]
+quit = "escape"
+save = "ctrl+s"
+cmdpanel = "ctrl+shift+p"
+quickopen = "ctrl+p"
+find = "ctrl+f"
+search = "ctrl+shift+f"
+problems = "ctrl+shift+m"
+
+[editor]
+undo = "ctrl+z"
+redo = ["ctrl+y", "ctrl+shift+z"]
+linestart = "home"
+lineend = "end"
+selectlinestart = "shift+home"
+selectlineend = "shift+end"
+goto = "ctrl+g"
+symbol = "ctrl+shift+o"
+hover = "ctrl+k ctrl+i"
+definition = "f12"
+autocomplete = "ctrl+space"
+
+[cmdpanel]
+closepanel = "escape"
+accept = ["enter", "numpadenter"]
+complete = "tab"
+selectnext = "down"
+selectprevious = "up"
+
+[find]
+closepanel = "escape"
+findnext = "enter"
+findprevious = "shift+enter"
+
+[search]
+closepanel = "escape"
+accept = ["enter", "numpadenter"]
+selectnext = "down"
+selectprevious = "up"
+
+[quickopen]
+closepanel = "escape"
+accept = ["enter", "numpadenter"]
+selectnext = "down"
+selectprevious = "up"
+
+[outline]
+closepanel = "escape"
+accept = ["enter", "numpadenter"]
+selectnext = "down"
+selectprevious = "up"
+
+[problems]
+closepanel = "escape"
+accept = ["enter", "numpadenter"]
+selectnext = "down"
+selectprevious = "up"
+
+[completion]
+closepanel = "escape"
+`
+
+type boundKey struct {
+	key  ebiten.Key
+	name string
+}
+
+// The ebiten keys a chord can use, with their keymap name
+var boundKeys = func() []boundKey {
+	var keys []boundKey
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k += 1 {
+		name := strings.ToLower(k.String())
+		name = strings.TrimPrefix(name, "arrow")
+		name = strings.TrimPrefix(name, "digit")
+		if keymap.IsKey(name) {
+			keys = append(keys, boundKey{key: k, name: name})
+		}
+	}
+	return keys
+}()
+
+// Build the keymap from the defaults and the user file
+func loadKeymap() (*keymap.Keymap, []error) {
+	km := keymap.New()
+	errs := km.Load(defaultKeymap)
+	data, err := os.ReadFile(keymapPath)
+	switch {
+	case err == nil:
+		errs = append(errs, km.Load(string(data))...)
+	case !os.IsNotExist(err):
+		errs = append(errs, err)
+	}
+	for _, name := range km.Commands() {
+		if _, exist := commands[name]; !exist {
+			errs = append(errs, fmt.Errorf("unknown command %s", name))
+		}
+	}
+	return km, errs
+}
+
+// Run the commands bound to the keys pressed this frame
+func (ed *Editor) updateKeys() {
+	var mods keymap.Modifiers
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		mods |= keymap.Ctrl
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		mods |= keymap.Shift
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyAlt) {
+		mods |= keymap.Alt
+	}
+	for _, k := range boundKeys {
+		if !inpututil.IsKeyJustPressed(k.key) {
+			continue
+		}
+		// The context is checked for every key, since
+		// a command can open or close a panel
+		chord := keymap.Chord{Mods: mods, Key: k.name}
+		if name, ok := ed.keymap.Press(chord, ed.keyContext(), globalContext); ok {
+			runCommand(name, nil)
+		}
+	}
+}
+
+// The context the keys are pressed in: the focused panel,
+// then any opened one, then the editor if a file has the focus
+func (ed *Editor) keyContext() string {
+	panels := []struct {
+		window  ui.WinHandle
+		context string
+		// Stays open while editing, so it only
+		// takes the keys while it has the focus
+		staysOpen bool
+	}{
+		{ed.cmdPanel.window, cmdPanelContext, false},
+		{ed.quickOpen.window, quickOpenContext, false},
+		{ed.searchPanel.window, searchContext, false},
+		{ed.findPanel.window, findContext, false},
+		{ed.outline.window, outlineContext, true},
+		{ed.problems.window, problemsContext, true},
+	}
+	for _, p := range panels {
+		if p.window.IsActive() && p.window.IsFocused() {
+			return p.context
+		}
+	}
+	// The popup takes its other keys from the TextBox
+	if textBox, _ := ed.textEd.activeFile(); textBox != nil && textBox.IsFocused() &&
+		textBox.Completion() != nil && textBox.Completion().Visible() {
+		return completionContext
+	}
+	for _, p := range panels {
+		if p.window.IsActive() && !p.staysOpen {
+			return p.context
+		}
+	}
+	if textBox, _ := ed.textEd.activeFile(); textBox != nil && textBox.IsFocused() {
+		return editorContext
+	}
+	return treeviewContext
+}
+
+// The result list of the focused panel, if it has one
+func (ed *Editor) focusedResultList() *ui.ResultList {
+	switch ed.keyContext() {
+	case searchContext:
+		return ed.searchPanel.resultList
+	case quickOpenContext:
+		return ed.quickOpen.resultList
+	case outlineContext:
+		return ed.outline.resultList
+	case problemsContext:
+		return ed.problems.resultList
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package editor
+
+import (
+	"runtime"
+	"strings"
+)
+
+const (
+	lineEndingLF lineEnding = iota
+	lineEndingCRLF
+	lineEndingCR
+	lineEndingMax
+)
+
+// The line terminator style of a file. The TextBox always stores
+// '\n', the original style is restored when the file is saved
+type lineEnding int
+
+func defaultLineEnding() lineEnding {
+	if runtime.GOOS == "windows" {
+		return lineEndingCRLF
+	}
+	return lineEndingLF
+}
+
+// Find the style used by most of the lines of the file.
+// <mixed> is set if more than one style was found
+func detectLineEnding(data []byte) (ending lineEnding, mixed bool) {
+	var counts [lineEndingMax]int
+	for i := 0; i < len(data); i += 1 {
+		switch data[i] {
+		case '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				counts[lineEndingCRLF] += 1
+				i += 1
+			} else {
+				counts[lineEndingCR] += 1
+			}
+		case '\n':
+			counts[lineEndingLF] += 1
+		}
+	}
+
+	ending = defaultLineEnding()
+	found := 0
+	for e, count := range counts {
+		if count == 0 {
+			continue
+		}
+		found += 1
+		if found == 1 || count > counts[ending] {
+			ending = lineEnding(e)
+		}
+	}
+	return ending, found > 1
+}
+
+func parseLineEnding(name string) (lineEnding, bool) {
+	for e := lineEnding(0); e < lineEndingMax; e += 1 {
+		if strings.EqualFold(name, e.String()) {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+func (e lineEnding) String() string {
+	switch e {
+	case lineEndingCRLF:
+		return "CRLF"
+	case lineEndingCR:
+		return "CR"
+	}
+	return "LF"
+}
+
+func (e lineEnding) terminator() string {
+	switch e {
+	case lineEndingCRLF:
+		return "\r\n"
+	case lineEndingCR:
+		return "\r"
+	}
+	return "\n"
+}
+
+// Convert the '\n' terminated text of a TextBox to the given style
+func applyLineEnding(text string, e lineEnding) string {
+	if e == lineEndingLF {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", e.terminator())
+}
@@ -0,0 +1,556 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nico-ec/uwu/cmdline"
+	"github.com/nico-ec/uwu/lsp"
+	"github.com/nico-ec/uwu/syntax"
+	"github.com/nico-ec/uwu/ui"
+)
+
+type (
+	// The language servers of the project, one per file extension,
+	// started with the first file using it. The clients block, so each
+	// server has a goroutine running its jobs in order, the results
+	// being sent back to the main goroutine
+	languageServers struct {
+		servers map[string]*languageServer
+		// The opened files sent to a server
+		documents map[*ui.TextBox]*lspDocument
+		// Run on the main goroutine each frame
+		results chan func()
+		// The last diagnostics of each file, by lsp.PathKey
+		// of its absolute path
+		diagnostics map[string]fileDiagnostics
+	}
+
+	fileDiagnostics struct {
+		// As the server wrote it
+		path  string
+		diags []lsp.Diagnostic
+	}
+
+	languageServer struct {
+		command string
+		// Set once the server is initialized, nil if it failed
+		client *lsp.Client
+
+		mu   sync.Mutex
+		jobs []func(c *lsp.Client)
+		// Wakes the goroutine up when a job is added
+		wake    chan struct{}
+		stopped bool
+	}
+
+	lspDocument struct {
+		server *languageServer
+		path   string
+		// The revision of the TextBox last sent
+		revision int
+	}
+)
+
+func (l *languageServers) init() {
+	l.servers = make(map[string]*languageServer)
+	l.documents = make(map[*ui.TextBox]*lspDocument)
+	l.results = make(chan func(), 64)
+	l.diagnostics = make(map[string]fileDiagnostics)
+	AddSignalListener(EditorProjectOpened, l)
+}
+
+// The servers are bound to the project they were started in
+func (l *languageServers) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorProjectOpened:
+		l.stopAll()
+	}
+}
+
+func (l *languageServers) stopAll() {
+	for _, server := range l.servers {
+		server.stop()
+	}
+	for textBox := range l.documents {
+		textBox.SetDiagnostics(nil)
+	}
+	l.servers = make(map[string]*languageServer)
+	l.documents = make(map[*ui.TextBox]*lspDocument)
+	l.diagnostics = make(map[string]fileDiagnostics)
+	FireSignal(EditorDiagnosticsChanged, nil)
+}
+
+// Send the file at <path> edited by <textBox> to the language server
+// of its extension, started if needed. Nothing is done without project
+// or if no server is set for the extension
+func (l *languageServers) open(textBox *ui.TextBox, path string) {
+	if ed.project.root == nil {
+		return
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	command := ed.settings.languageServers[ext]
+	if strings.TrimSpace(command) == "" {
+		return
+	}
+	server, exist := l.servers[ext]
+	if !exist || server.command != command {
+		previous := server
+		if exist {
+			previous.stop()
+		}
+		server = l.start(command)
+		l.servers[ext] = server
+		// The files sent to the previous server go to the new one
+		for other, doc := range l.documents {
+			if doc.server == previous && other != textBox {
+				l.open(other, doc.path)
+			}
+		}
+	}
+
+	languageID := ext
+	if lexer := syntax.ForFile(path); lexer != nil {
+		languageID = strings.ToLower(lexer.Language().Name)
+	}
+	// The diagnostics are kept by absolute path, as the servers send them
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	text := string(textBox.GetCharBuffer())
+	l.documents[textBox] = &lspDocument{
+		server:   server,
+		path:     path,
+		revision: textBox.Revision(),
+	}
+	server.push(func(c *lsp.Client) {
+		c.DidOpen(path, languageID, text)
+	})
+	// Servers can report files before they are opened
+	l.showDiagnostics(textBox, path)
+}
+
+func (l *languageServers) start(command string) *languageServer {
+	server := &languageServer{
+		command: command,
+		wake:    make(chan struct{}, 1),
+	}
+	root := ed.project.root.nodePath
+	go func() {
+		client, err := startClient(command, root)
+		if err != nil {
+			l.results <- func() {
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorWarning,
+					Msg:  fmt.Sprintf("Could not start the language server '%s': %s", command, err),
+				})
+			}
+			// The jobs are dropped, the server isn't started again
+			client = nil
+		} else {
+			l.results <- func() {
+				server.client = client
+			}
+		}
+		server.run(client)
+	}()
+	return server
+}
+
+func startClient(command string, root string) (*lsp.Client, error) {
+	args, err := cmdline.Split(command)
+	if err != nil {
+		return nil, err
+	}
+	client, err := lsp.Start(args, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Initialize(root); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Run the jobs until the server is stopped. They are
+// dropped if the client is nil
+func (s *languageServer) run(client *lsp.Client) {
+	for {
+		s.mu.Lock()
+		jobs, stopped := s.jobs, s.stopped
+		s.jobs = nil
+		s.mu.Unlock()
+		if client != nil {
+			for _, job := range jobs {
+				job(client)
+			}
+		}
+		if stopped {
+			if client != nil {
+				client.Close()
+			}
+			return
+		}
+		if len(jobs) == 0 {
+			<-s.wake
+		}
+	}
+}
+
+func (s *languageServer) push(job func(c *lsp.Client)) {
+	s.mu.Lock()
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shut the server down once its pending jobs are run
+func (s *languageServer) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run the results of the servers, send the edits
+// and pick the new diagnostics up
+func (l *languageServers) update() {
+	for {
+		select {
+		case result := <-l.results:
+			result()
+			continue
+		default:
+		}
+		break
+	}
+
+	for textBox, doc := range l.documents {
+		if _, opened := ed.textEd.files[textBox]; !opened {
+			path := doc.path
+			doc.server.push(func(c *lsp.Client) {
+				c.DidClose(path)
+			})
+			delete(l.documents, textBox)
+			continue
+		}
+		l.sync(textBox, doc)
+	}
+
+	changed := make(map[string]bool)
+	for _, server := range l.servers {
+		if server.client == nil {
+			continue
+		}
+		for uri, diags := range server.client.TakeDiagnostics() {
+			if path := lsp.URIToPath(uri); path != "" {
+				key := lsp.PathKey(path)
+				l.diagnostics[key] = fileDiagnostics{path: path, diags: diags}
+				changed[key] = true
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	for textBox, doc := range l.documents {
+		if changed[lsp.PathKey(doc.path)] {
+			l.showDiagnostics(textBox, doc.path)
+		}
+	}
+	FireSignal(EditorDiagnosticsChanged, nil)
+}
+
+// Send the edits made to <textBox> since the last time
+func (l *languageServers) sync(textBox *ui.TextBox, doc *lspDocument) {
+	if textBox.Revision() == doc.revision {
+		return
+	}
+	doc.revision = textBox.Revision()
+	path, text := doc.path, string(textBox.GetCharBuffer())
+	doc.server.push(func(c *lsp.Client) {
+		c.DidChange(path, text)
+	})
+}
+
+// Draw the diagnostics of the file at <path> in <textBox>
+func (l *languageServers) showDiagnostics(textBox *ui.TextBox, path string) {
+	diags := l.diagnostics[lsp.PathKey(path)].diags
+	converted := make([]ui.Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		converted = append(converted, ui.Diagnostic{
+			Range: ui.TextRange{
+				Start: textOffset(textBox, d.Range.Start),
+				End:   textOffset(textBox, d.Range.End),
+			},
+			Severity: diagnosticSeverity(d.Severity),
+			Message:  diagnosticMessage(d),
+		})
+	}
+	textBox.SetDiagnostics(converted)
+}
+
+func diagnosticSeverity(s lsp.DiagnosticSeverity) ui.DiagnosticSeverity {
+	switch s {
+	case lsp.SeverityWarning:
+		return ui.DiagnosticWarning
+	case lsp.SeverityInformation, lsp.SeverityHint:
+		return ui.DiagnosticInfo
+	}
+	return ui.DiagnosticError
+}
+
+// The message of <d>, prefixed with the tool that found it
+func diagnosticMessage(d lsp.Diagnostic) string {
+	if d.Source == "" {
+		return d.Message
+	}
+	return d.Source + ": " + d.Message
+}
+
+func (l *languageServers) saved(textBox *ui.TextBox) {
+	doc, exist := l.documents[textBox]
+	if !exist {
+		return
+	}
+	path, text := doc.path, string(textBox.GetCharBuffer())
+	doc.server.push(func(c *lsp.Client) {
+		c.DidSave(path, text)
+	})
+}
+
+// Run <request> with the server of the active file and the position of
+// its caret. The function it returns is then run on the main goroutine
+func (l *languageServers) request(request func(c *lsp.Client, path string, pos lsp.Position) func()) {
+	textBox, _ := ed.textEd.activeFile()
+	doc, exist := l.documents[textBox]
+	switch {
+	case !exist:
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No language server for this file",
+		})
+		return
+	// The jobs would be dropped
+	case doc.server.client == nil:
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "The language server isn't running",
+		})
+		return
+	}
+	pos := caretPosition(textBox)
+	path := doc.path
+	doc.server.push(func(c *lsp.Client) {
+		if result := request(c, path, pos); result != nil {
+			l.results <- result
+		}
+	})
+}
+
+// The position of the caret, as the servers count it
+func caretPosition(t *ui.TextBox) lsp.Position {
+	return offsetPosition(t, t.Caret())
+}
+
+// The position of <offset> in the buffer of <t>, as the servers count it
+func offsetPosition(t *ui.TextBox, offset int) lsp.Position {
+	buf := t.Buffer()
+	ln := buf.LineAt(offset)
+	line := buf.Slice(buf.LineStart(ln), buf.LineEnd(ln))
+	return lsp.Position{
+		Line:      ln,
+		Character: lsp.UTF16Column(line, offset-buf.LineStart(ln)),
+	}
+}
+
+// The line (starting at 1) and column of <pos> in <t>
+func textPosition(t *ui.TextBox, pos lsp.Position) (int, int) {
+	buf := t.Buffer()
+	ln := pos.Line
+	if ln >= buf.LineCount() {
+		ln = buf.LineCount() - 1
+	}
+	if ln < 0 {
+		ln = 0
+	}
+	line := buf.Slice(buf.LineStart(ln), buf.LineEnd(ln))
+	return ln + 1, lsp.RuneColumn(line, pos.Character)
+}
+
+// The offset of <pos> in the buffer of <t>
+func textOffset(t *ui.TextBox, pos lsp.Position) int {
+	line, col := textPosition(t, pos)
+	return t.Buffer().LineStart(line-1) + col
+}
+
+// Count the diagnostics of every file, like "2 errors, 1 warning"
+func (l *languageServers) diagnosticsSummary() string {
+	counts := make(map[lsp.DiagnosticSeverity]int)
+	for _, file := range l.diagnostics {
+		for _, d := range file.diags {
+			severity := d.Severity
+			// A missing severity is an error
+			if severity == 0 {
+				severity = lsp.SeverityError
+			}
+			counts[severity] += 1
+		}
+	}
+	var parts []string
+	for _, severity := range []lsp.DiagnosticSeverity{
+		lsp.SeverityError, lsp.SeverityWarning, lsp.SeverityInformation, lsp.SeverityHint,
+	} {
+		n := counts[severity]
+		switch {
+		case n == 1:
+			parts = append(parts, fmt.Sprintf("1 %s", severity))
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, severity))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Show the description of the symbol under the caret
+func (l *languageServers) hover() {
+	l.request(func(c *lsp.Client, path string, pos lsp.Position) func() {
+		text, err := c.Hover(path, pos)
+		return func() {
+			switch {
+			case err != nil:
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorWarning,
+					Msg:  "Hover failed: " + err.Error(),
+				})
+			case strings.TrimSpace(text) == "":
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorDebug,
+					Msg:  "Nothing to show here",
+				})
+			default:
+				// The status bar holds a single line
+				text = strings.Join(strings.Fields(text), " ")
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorDebug,
+					Msg:  text,
+				})
+			}
+		}
+	})
+}
+
+// Jump to the definition of the symbol under the caret
+func (l *languageServers) definition() {
+	l.request(func(c *lsp.Client, path string, pos lsp.Position) func() {
+		locs, err := c.Definition(path, pos)
+		return func() {
+			switch {
+			case err != nil:
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorWarning,
+					Msg:  "Go to definition failed: " + err.Error(),
+				})
+			case len(locs) == 0:
+				FireSignal(EditorErrorRaised, SignalError{
+					Kind: editorWarning,
+					Msg:  "No definition found",
+				})
+			default:
+				openLocation(lsp.URIToPath(locs[0].URI), locs[0].Range.Start)
+			}
+		}
+	})
+}
+
+// Open the project file at <path> and move its caret to <pos>
+func openLocation(path string, pos lsp.Position) bool {
+	if !openProjectPath(path) {
+		return false
+	}
+	if textBox, _ := ed.textEd.activeFile(); textBox != nil {
+		line, col := textPosition(textBox, pos)
+		ed.textEd.jumpTo(line, col)
+	}
+	return true
+}
+
+// Offers the completions of the language server of a file. The
+// words of the file are offered while the server is asked, and
+// when the file has no server
+type serverCompletion struct {
+	// The completions received for <prefix>, typed at
+	// <wordStart>. nil until the server answers
+	wordStart int
+	prefix    string
+	items     []ui.Completion
+	// The server asked, nil once it answered. A stopped server
+	// drops the request, so another server is asked again
+	asking *languageServer
+}
+
+func (c *serverCompletion) Completions(t *ui.TextBox, prefix string) []ui.Completion {
+	doc, exist := ed.lsp.documents[t]
+	if !exist || doc.server.client == nil {
+		return ui.WordCompletion{}.Completions(t, prefix)
+	}
+	start := t.Caret() - utf8.RuneCountInString(prefix)
+	// The servers filter the completions with what is typed,
+	// those of a shorter prefix are missing some
+	if c.items != nil && start == c.wordStart && strings.HasPrefix(strings.ToLower(prefix), strings.ToLower(c.prefix)) {
+		if len(c.items) == 0 {
+			return ui.WordCompletion{}.Completions(t, prefix)
+		}
+		return c.items
+	}
+	if c.asking != doc.server {
+		server := doc.server
+		c.asking = server
+		ed.lsp.sync(t, doc)
+		pos := offsetPosition(t, t.Caret())
+		path := doc.path
+		server.push(func(client *lsp.Client) {
+			items, err := client.Completion(path, pos)
+			ed.lsp.results <- func() {
+				if c.asking == server {
+					c.asking = nil
+				}
+				c.received(t, start, prefix, items, err)
+			}
+		})
+	}
+	return ui.WordCompletion{}.Completions(t, prefix)
+}
+
+// Keep the completions the server sent, and show them
+// if the popup is still open
+func (c *serverCompletion) received(t *ui.TextBox, start int, prefix string, items []lsp.CompletionItem, err error) {
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorDebug,
+			Msg:  "Completion failed: " + err.Error(),
+		})
+		return
+	}
+	c.wordStart, c.prefix = start, prefix
+	c.items = make([]ui.Completion, 0, len(items))
+	for _, item := range items {
+		c.items = append(c.items, ui.Completion{
+			Text:   item.Text(),
+			Detail: item.Detail,
+		})
+	}
+	if popup := t.Completion(); popup != nil {
+		popup.Refresh()
+	}
+}
@@ -0,0 +1,221 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nico-ec/uwu/fuzzy"
+	"github.com/nico-ec/uwu/outline"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// The declarations of the active Go file. Opened with ":outline" it
+// stays open and follows the edits, opened with ":symbol" it is closed
+// once a declaration is picked
+type OutlinePanel struct {
+	window     ui.WinHandle
+	queryBox   *ui.TextBox
+	resultList *ui.ResultList
+
+	// Closed after a jump
+	picking bool
+
+	// The file the symbols were read from, and its revision then
+	textBox  *ui.TextBox
+	revision int
+	symbols  []outline.Symbol
+	names    []string
+	// Indices in <symbols> of the entries shown in the list
+	ranked []int
+	query  string
+}
+
+func (o *OutlinePanel) initOutlinePanel() {
+	theme := getTheme()
+	o.window = ui.AddWindow(ui.Window{
+		Active:       false,
+		FocusOnClick: true,
+		Rect:         ui.Rectangle{X: 1150, Y: 60, Width: 420, Height: 600},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderRow,
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
+		},
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr1,
+		},
+		HasHeader:    true,
+		HeaderHeight: 20,
+		HeaderBackground: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundImageSlice,
+			Clr:     theme.dividerClr,
+			Img:     &ed.header,
+			Constr:  ui.Constraint{Left: 2, Right: 2, Up: 2, Down: 2},
+		},
+		HasHeaderTitle: true,
+		HeaderTitle:    "Outline",
+		HeaderFont:     &ed.font,
+		HeaderFontSize: 12,
+		HeaderFontClr:  theme.normalTextClr,
+
+		HasBorders:  true,
+		BorderWidth: 1,
+		BorderColor: theme.dividerClr,
+	})
+	o.window.SetCloseBtn(ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       editorCloseBtn,
+		Clr:          theme.backgroundClr3,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasIcon:      true,
+		Icon:         &ed.cross,
+		IconClr:      theme.backgroundClr1,
+		Receiver:     o,
+	})
+
+	o.queryBox = &ui.TextBox{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Cap:       200,
+		Margin:    3,
+		Font:      &ed.font,
+		TextSize:  12,
+		TextClr:   theme.normalTextClr,
+		Multiline: false,
+	}
+	o.resultList = &ui.ResultList{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Margin: ui.Point{3, 3},
+		},
+		Font:     &ed.font,
+		TextSize: 12,
+		TextClr:  theme.normalTextClr,
+		Receiver: o,
+	}
+	o.window.AddWidget(o.queryBox, 22)
+	o.window.AddWidget(o.resultList, ui.FitContainer)
+	o.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, o)
+}
+
+func (o *OutlinePanel) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		o.window.SetColors(theme.windowColors())
+		o.queryBox.Background.Clr = theme.backgroundClr2
+		o.queryBox.TextClr = theme.normalTextClr
+		o.resultList.TextClr = theme.normalTextClr
+	}
+}
+
+// Read the symbols again when the active file or its content
+// changed, and filter them again when the query changed
+func (o *OutlinePanel) updateOutlinePanel() {
+	if !o.window.IsActive() {
+		return
+	}
+
+	textBox, file := ed.textEd.activeFile()
+	switch {
+	case textBox != o.textBox || textBox != nil && textBox.Revision() != o.revision:
+		o.read(textBox, file)
+		o.rank(string(o.queryBox.GetCharBuffer()))
+	case string(o.queryBox.GetCharBuffer()) != o.query:
+		o.rank(string(o.queryBox.GetCharBuffer()))
+	}
+}
+
+func (o *OutlinePanel) read(textBox *ui.TextBox, file *editedFile) {
+	o.textBox = textBox
+	o.symbols = o.symbols[:0]
+	o.names = o.names[:0]
+	if textBox == nil || !isGoFile(file) {
+		return
+	}
+	o.revision = textBox.Revision()
+	o.symbols = outline.ParseGo([]byte(string(textBox.GetCharBuffer())))
+	for _, s := range o.symbols {
+		o.names = append(o.names, s.Name)
+	}
+}
+
+// The symbols in the order of the file, or
+// the best matches of <query> first
+func (o *OutlinePanel) rank(query string) {
+	o.query = query
+	o.ranked = o.ranked[:0]
+	if strings.TrimSpace(query) == "" {
+		for i := range o.symbols {
+			o.ranked = append(o.ranked, i)
+		}
+	} else {
+		for _, m := range fuzzy.Rank(query, o.names, 0) {
+			o.ranked = append(o.ranked, m.Index)
+		}
+	}
+	items := make([]string, len(o.ranked))
+	for i, index := range o.ranked {
+		s := o.symbols[index]
+		items[i] = fmt.Sprintf("%-6s %s  %d", s.Kind, s.Name, s.Line)
+	}
+	o.resultList.SetItems(items)
+}
+
+func isGoFile(file *editedFile) bool {
+	return file != nil && filepath.Ext(file.node.path()) == ".go"
+}
+
+// Show the panel, following the edits when not <picking>
+func (o *OutlinePanel) open(picking bool) {
+	if _, file := ed.textEd.activeFile(); !isGoFile(file) {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "The outline is only available for Go files",
+		})
+		return
+	}
+	o.picking = picking
+	// Read the file right away, the list would be empty for a frame
+	o.textBox = nil
+	o.queryBox.EmptyCharBuffer()
+	o.window.SetActive(true)
+	o.updateOutlinePanel()
+	o.queryBox.SetFocus(true)
+}
+
+func (o *OutlinePanel) toggle(picking bool) {
+	if o.window.IsActive() && o.picking == picking {
+		o.window.SetActive(false)
+	} else {
+		o.open(picking)
+	}
+}
+
+func (o *OutlinePanel) OnResultSelected(index int) {
+	s := o.symbols[o.ranked[index]]
+	ed.textEd.jumpTo(s.Line, s.Column)
+	if o.picking {
+		o.window.SetActive(false)
+	} else {
+		focusEditor()
+	}
+}
+
+func (o *OutlinePanel) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
+	o.window.SetActive(false)
+}
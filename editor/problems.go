@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nico-ec/uwu/fuzzy"
+	"github.com/nico-ec/uwu/lsp"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// The diagnostics of every file of the project. It stays open
+// and follows the new diagnostics, picking one jumps to it
+type ProblemsPanel struct {
+	window     ui.WinHandle
+	queryBox   *ui.TextBox
+	resultList *ui.ResultList
+
+	problems []problem
+	names    []string
+	// Indices in <problems> of the entries shown in the list
+	ranked []int
+	query  string
+}
+
+type problem struct {
+	path       string
+	diagnostic lsp.Diagnostic
+}
+
+func (p *ProblemsPanel) initProblemsPanel() {
+	theme := getTheme()
+	p.window = ui.AddWindow(ui.Window{
+		Active:       false,
+		FocusOnClick: true,
+		Rect:         ui.Rectangle{X: 300, Y: 560, Width: 1000, Height: 300},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderRow,
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
+		},
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr1,
+		},
+		HasHeader:    true,
+		HeaderHeight: 20,
+		HeaderBackground: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundImageSlice,
+			Clr:     theme.dividerClr,
+			Img:     &ed.header,
+			Constr:  ui.Constraint{Left: 2, Right: 2, Up: 2, Down: 2},
+		},
+		HasHeaderTitle: true,
+		HeaderTitle:    "Problems",
+		HeaderFont:     &ed.font,
+		HeaderFontSize: 12,
+		HeaderFontClr:  theme.normalTextClr,
+
+		HasBorders:  true,
+		BorderWidth: 1,
+		BorderColor: theme.dividerClr,
+	})
+	p.window.SetCloseBtn(ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       editorCloseBtn,
+		Clr:          theme.backgroundClr3,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasIcon:      true,
+		Icon:         &ed.cross,
+		IconClr:      theme.backgroundClr1,
+		Receiver:     p,
+	})
+
+	p.queryBox = &ui.TextBox{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Cap:       200,
+		Margin:    3,
+		Font:      &ed.font,
+		TextSize:  12,
+		TextClr:   theme.normalTextClr,
+		Multiline: false,
+	}
+	p.resultList = &ui.ResultList{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Margin: ui.Point{3, 3},
+		},
+		Font:     &ed.font,
+		TextSize: 12,
+		TextClr:  theme.normalTextClr,
+		Receiver: p,
+	}
+	p.window.AddWidget(p.queryBox, 22)
+	p.window.AddWidget(p.resultList, ui.FitContainer)
+	p.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, p)
+	AddSignalListener(EditorDiagnosticsChanged, p)
+}
+
+func (p *ProblemsPanel) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		p.window.SetColors(theme.windowColors())
+		p.queryBox.Background.Clr = theme.backgroundClr2
+		p.queryBox.TextClr = theme.normalTextClr
+		p.resultList.TextClr = theme.normalTextClr
+	case EditorDiagnosticsChanged:
+		if p.window.IsActive() {
+			p.read()
+			p.rank(p.query)
+		}
+	}
+}
+
+// Filter the problems again when the query changed
+func (p *ProblemsPanel) updateProblemsPanel() {
+	if !p.window.IsActive() {
+		return
+	}
+	if query := string(p.queryBox.GetCharBuffer()); query != p.query {
+		p.rank(query)
+	}
+}
+
+// Collect the diagnostics, sorted by file and position
+func (p *ProblemsPanel) read() {
+	p.problems = p.problems[:0]
+	for _, file := range ed.lsp.diagnostics {
+		for _, d := range file.diags {
+			p.problems = append(p.problems, problem{path: file.path, diagnostic: d})
+		}
+	}
+	sort.Slice(p.problems, func(i, j int) bool {
+		a, b := p.problems[i], p.problems[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.diagnostic.Range.Start.Line != b.diagnostic.Range.Start.Line {
+			return a.diagnostic.Range.Start.Line < b.diagnostic.Range.Start.Line
+		}
+		return a.diagnostic.Range.Start.Character < b.diagnostic.Range.Start.Character
+	})
+
+	p.names = p.names[:0]
+	for _, pb := range p.problems {
+		p.names = append(p.names, pb.text())
+	}
+}
+
+// The line of <pb> in the list, like
+// "error   main.go:12:5  undefined: x"
+func (pb problem) text() string {
+	d := pb.diagnostic
+	message := strings.SplitN(diagnosticMessage(d), "\n", 2)[0]
+	return fmt.Sprintf("%-7s %s:%d:%d  %s",
+		d.Severity, ed.project.relativePath(pb.path), d.Range.Start.Line+1, d.Range.Start.Character+1, message)
+}
+
+// The problems in the order of the files, or
+// the best matches of <query> first
+func (p *ProblemsPanel) rank(query string) {
+	p.query = query
+	p.ranked = p.ranked[:0]
+	if strings.TrimSpace(query) == "" {
+		for i := range p.problems {
+			p.ranked = append(p.ranked, i)
+		}
+	} else {
+		for _, m := range fuzzy.Rank(query, p.names, 0) {
+			p.ranked = append(p.ranked, m.Index)
+		}
+	}
+	items := make([]string, len(p.ranked))
+	for i, index := range p.ranked {
+		items[i] = p.names[index]
+	}
+	p.resultList.SetItems(items)
+}
+
+func (p *ProblemsPanel) open() {
+	p.queryBox.EmptyCharBuffer()
+	p.read()
+	p.rank("")
+	p.window.SetActive(true)
+	p.queryBox.SetFocus(true)
+}
+
+func (p *ProblemsPanel) toggle() {
+	if p.window.IsActive() {
+		p.window.SetActive(false)
+	} else {
+		p.open()
+	}
+}
+
+func (p *ProblemsPanel) OnResultSelected(index int) {
+	pb := p.problems[p.ranked[index]]
+	if openLocation(pb.path, pb.diagnostic.Range.Start) {
+		focusEditor()
+	}
+}
+
+func (p *ProblemsPanel) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
+	p.window.SetActive(false)
+}
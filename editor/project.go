@@ -3,6 +3,8 @@ package editor
 import (
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var exceptionList = []string{
@@ -87,6 +89,61 @@ func (p *project) findNode(name string) projectNode {
 	return p.root.findChild(name)
 }
 
+// Find a node from its path, either absolute
+// or relative to the root of the project
+func (p *project) findNodeByPath(path string) projectNode {
+	rel := p.relativePath(path)
+	if rel == "" {
+		return nil
+	}
+	var node projectNode = p.root
+	for _, name := range strings.Split(rel, "/") {
+		dir, ok := node.(*folder)
+		if !ok {
+			return nil
+		}
+		if node, ok = dir.nodes[name]; !ok {
+			return nil
+		}
+	}
+	return node
+}
+
+// Convert a path to a path relative to the root
+// of the project, using '/' as separator
+func (p *project) relativePath(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	root := filepath.ToSlash(filepath.Clean(p.root.nodePath))
+	// The paths given by the language servers are absolute
+	if filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(p.root.nodePath); err == nil {
+			root = filepath.ToSlash(abs)
+		}
+	}
+	if rel := strings.TrimPrefix(path, root+"/"); rel != path {
+		return rel
+	}
+	return strings.TrimPrefix(path, "./")
+}
+
+// All the files of the project, depth first
+func (p *project) files() []file {
+	files := make([]file, 0, 64)
+	var walk func(f *folder)
+	walk = func(f *folder) {
+		for _, node := range f.nodes {
+			switch n := node.(type) {
+			case *folder:
+				walk(n)
+			case file:
+				files = append(files, n)
+			}
+		}
+	}
+	walk(p.root)
+	return files
+}
+
 func (f *folder) addSubFolder(entry fs.DirEntry) {
 	// No need to check for existing one since the
 	// OS garantees that filenames are unique
@@ -149,6 +206,10 @@ func (f file) getEntry() fs.DirEntry {
 }
 
 func isDirException(path string) bool {
+	// The project settings are edited like any other file
+	if path == projectSettingsName {
+		return false
+	}
 	if path[0] == '.' {
 		return true
 	}
@@ -0,0 +1,409 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nico-ec/uwu/ui"
+)
+
+const (
+	// Above that, the search is stopped
+	searchMaxResults = 5000
+	// Files bigger than that are most likely not source files
+	searchMaxFileSize = 8 << 20
+	// How far to look for a NUL byte to tell binary files apart
+	searchBinaryProbe = 8000
+	// Results taken from the workers each frame,
+	// so the UI stays responsive on big projects
+	searchResultsPerFrame = 200
+	searchPreviewLength   = 80
+)
+
+type (
+	searchResult struct {
+		name    string
+		path    string
+		line    int
+		column  int
+		preview string
+	}
+
+	// A running search. The workers send their results
+	// on <results>, which is closed once they are all done
+	projectSearch struct {
+		re      *regexp.Regexp
+		results chan searchResult
+		cancel  chan struct{}
+		once    sync.Once
+	}
+)
+
+func startProjectSearch(root *folder, re *regexp.Regexp) *projectSearch {
+	s := &projectSearch{
+		re:      re,
+		results: make(chan searchResult, searchResultsPerFrame),
+		cancel:  make(chan struct{}),
+	}
+	paths := make(chan file)
+	go func() {
+		defer close(paths)
+		s.walk(root, paths)
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i += 1 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for f := range paths {
+				s.searchFile(f)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(s.results)
+	}()
+	return s
+}
+
+// Send all the files of the tree to the workers. Entries of
+// the exceptionList were already left out by project.readDir
+func (s *projectSearch) walk(f *folder, paths chan<- file) bool {
+	for _, node := range f.nodes {
+		switch n := node.(type) {
+		case *folder:
+			if !s.walk(n, paths) {
+				return false
+			}
+		case file:
+			select {
+			case paths <- n:
+			case <-s.cancel:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *projectSearch) searchFile(f file) {
+	info, err := f.entry.Info()
+	if err != nil || info.Size() > searchMaxFileSize {
+		return
+	}
+	data, err := os.ReadFile(f.path())
+	if err != nil || isBinary(data) {
+		return
+	}
+
+	for ln := 1; len(data) > 0; ln += 1 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			end = len(data)
+		}
+		line := bytes.TrimRight(data[:end], "\r")
+		if loc := s.re.FindIndex(line); loc != nil {
+			result := searchResult{
+				name:    f.name(),
+				path:    f.path(),
+				line:    ln,
+				column:  utf8.RuneCount(line[:loc[0]]),
+				preview: makePreview(line),
+			}
+			select {
+			case s.results <- result:
+			case <-s.cancel:
+				return
+			}
+		}
+		if end == len(data) {
+			break
+		}
+		data = data[end+1:]
+	}
+}
+
+func (s *projectSearch) stop() {
+	s.once.Do(func() {
+		close(s.cancel)
+	})
+}
+
+func isBinary(data []byte) bool {
+	probe := data
+	if len(probe) > searchBinaryProbe {
+		probe = probe[:searchBinaryProbe]
+		// The cut can fall in the middle of a rune
+		for i := len(probe) - 1; i >= 0 && i > len(probe)-utf8.UTFMax; i -= 1 {
+			if utf8.RuneStart(probe[i]) {
+				if !utf8.FullRune(probe[i:]) {
+					probe = probe[:i]
+				}
+				break
+			}
+		}
+	}
+	return bytes.IndexByte(probe, 0) >= 0 || !utf8.Valid(probe)
+}
+
+func makePreview(line []byte) string {
+	preview := strings.TrimSpace(string(line))
+	preview = strings.ReplaceAll(preview, "\t", " ")
+	if utf8.RuneCountInString(preview) > searchPreviewLength {
+		preview = string([]rune(preview)[:searchPreviewLength]) + "..."
+	}
+	return preview
+}
+
+//
+// Search panel
+//
+
+type SearchPanel struct {
+	window      ui.WinHandle
+	queryBox    *ui.TextBox
+	statusLabel *ui.Label
+	resultList  *ui.ResultList
+
+	search *projectSearch
+	query  string
+	// Set by the flags of ":search", reset when the panel is opened
+	options findOptions
+	results []searchResult
+	files   map[string]bool
+}
+
+func (s *SearchPanel) initSearchPanel() {
+	theme := getTheme()
+	s.window = ui.AddWindow(ui.Window{
+		Active: false,
+		Rect:   ui.Rectangle{X: 300, Y: 150, Width: 700, Height: 420},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderRow,
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
+		},
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr1,
+		},
+		HasHeader:    true,
+		HeaderHeight: 20,
+		HeaderBackground: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundImageSlice,
+			Clr:     theme.dividerClr,
+			Img:     &ed.header,
+			Constr:  ui.Constraint{Left: 2, Right: 2, Up: 2, Down: 2},
+		},
+		HasHeaderTitle: true,
+		HeaderTitle:    "Search in project",
+		HeaderFont:     &ed.font,
+		HeaderFontSize: 12,
+		HeaderFontClr:  theme.normalTextClr,
+
+		HasBorders:  true,
+		BorderWidth: 1,
+		BorderColor: theme.dividerClr,
+	})
+	s.window.SetCloseBtn(ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       editorCloseBtn,
+		Clr:          theme.backgroundClr3,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasIcon:      true,
+		Icon:         &ed.cross,
+		IconClr:      theme.backgroundClr1,
+		Receiver:     s,
+	})
+
+	s.queryBox = &ui.TextBox{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Cap:       200,
+		Margin:    3,
+		Font:      &ed.font,
+		TextSize:  12,
+		TextClr:   theme.normalTextClr,
+		Multiline: false,
+	}
+	s.statusLabel = &ui.Label{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Font:  &ed.font,
+		Text:  "",
+		Align: ui.TextAlignCenterLeft,
+		Clr:   theme.normalTextClr,
+		Size:  12,
+	}
+	s.resultList = &ui.ResultList{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Margin: ui.Point{3, 3},
+		},
+		Font:     &ed.font,
+		TextSize: 12,
+		TextClr:  theme.normalTextClr,
+		Receiver: s,
+	}
+	s.window.AddWidget(s.queryBox, 22)
+	s.window.AddWidget(s.statusLabel, 16)
+	s.window.AddWidget(s.resultList, ui.FitContainer)
+	s.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, s)
+}
+
+func (s *SearchPanel) OnSignal(signal Signal) {
+	switch signal.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		s.window.SetColors(theme.windowColors())
+		s.queryBox.Background.Clr = theme.backgroundClr2
+		s.queryBox.TextClr = theme.normalTextClr
+		s.statusLabel.Clr = theme.normalTextClr
+		s.resultList.TextClr = theme.normalTextClr
+	}
+}
+
+func (s *SearchPanel) updateSearchPanel() {
+	s.receiveResults()
+}
+
+// Run the search, then open the selected result
+// as long as the query stays the same
+func (s *SearchPanel) accept() {
+	query := string(s.queryBox.GetCharBuffer())
+	if query != s.query || s.search == nil && len(s.results) == 0 {
+		s.start(query)
+	} else {
+		s.resultList.Activate()
+	}
+}
+
+func (s *SearchPanel) toggle() {
+	if s.window.IsActive() {
+		s.close()
+	} else {
+		s.open()
+	}
+}
+
+func (s *SearchPanel) open() {
+	s.options = findOptions{}
+	s.window.SetActive(true)
+	s.queryBox.SetFocus(true)
+}
+
+func (s *SearchPanel) close() {
+	s.window.SetActive(false)
+}
+
+// Open the panel and search <query> right away
+func (s *SearchPanel) searchFor(query string, options findOptions) {
+	s.open()
+	s.options = options
+	s.queryBox.LoadBufferData([]rune(query))
+	s.start(query)
+}
+
+func (s *SearchPanel) start(query string) {
+	if s.search != nil {
+		s.search.stop()
+		s.search = nil
+	}
+	s.query = query
+	s.results = s.results[:0]
+	s.files = make(map[string]bool)
+	s.resultList.Clear()
+	s.statusLabel.SetText("")
+	if query == "" {
+		return
+	}
+
+	if ed.project.root == nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No project opened",
+		})
+		return
+	}
+	re, err := compileFindPattern(query, s.options)
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  "Invalid search pattern: " + err.Error(),
+		})
+		return
+	}
+	s.search = startProjectSearch(ed.project.root, re)
+	s.statusLabel.SetText("Searching...")
+}
+
+// Move the results found since the last frame into the list
+func (s *SearchPanel) receiveResults() {
+	if s.search == nil {
+		return
+	}
+	for i := 0; i < searchResultsPerFrame; i += 1 {
+		select {
+		case r, ok := <-s.search.results:
+			if !ok {
+				s.search = nil
+				s.statusLabel.SetText(s.resultCountText())
+				return
+			}
+			s.addResult(r)
+			if len(s.results) >= searchMaxResults {
+				s.search.stop()
+				s.search = nil
+				s.statusLabel.SetText(s.resultCountText() + " (stopped, too many results)")
+				return
+			}
+		default:
+			s.statusLabel.SetText("Searching... " + s.resultCountText())
+			return
+		}
+	}
+}
+
+func (s *SearchPanel) addResult(r searchResult) {
+	s.files[r.path] = true
+	s.results = append(s.results, r)
+	s.resultList.AddItems(fmt.Sprintf("%s:%d  %s", r.name, r.line, r.preview))
+}
+
+func (s *SearchPanel) resultCountText() string {
+	return fmt.Sprintf("%d results in %d files", len(s.results), len(s.files))
+}
+
+func (s *SearchPanel) OnResultSelected(index int) {
+	r := s.results[index]
+	if openProjectPath(r.path) {
+		ed.textEd.jumpTo(r.line, r.column)
+	}
+}
+
+func (s *SearchPanel) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
+	if id == editorCloseBtn {
+		s.close()
+	}
+}
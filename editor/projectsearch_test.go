@@ -0,0 +1,20 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	// A rune of 3 bytes across the end of the probe
+	text := append(bytes.Repeat([]byte("a"), searchBinaryProbe-1), "€ and more"...)
+	if isBinary(text) {
+		t.Errorf("A rune cut by the probe shouldn't make the file binary")
+	}
+	if !isBinary([]byte("text\x00more")) {
+		t.Errorf("A NUL byte makes the file binary")
+	}
+	if !isBinary([]byte("text\xffmore")) {
+		t.Errorf("Invalid UTF-8 makes the file binary")
+	}
+}
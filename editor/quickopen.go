@@ -0,0 +1,173 @@
+package editor
+
+import (
+	"github.com/nico-ec/uwu/fuzzy"
+	"github.com/nico-ec/uwu/ui"
+)
+
+const quickOpenMaxResults = 100
+
+// Ctrl+P window opening project files from a fuzzy match of their path
+type QuickOpen struct {
+	window     ui.WinHandle
+	queryBox   *ui.TextBox
+	resultList *ui.ResultList
+
+	// Paths relative to the project root, gathered when the window opens
+	paths []string
+	// Indices in <paths> of the entries shown in the list
+	ranked []int
+	query  string
+}
+
+func (q *QuickOpen) initQuickOpen() {
+	theme := getTheme()
+	q.window = ui.AddWindow(ui.Window{
+		Active: false,
+		Rect:   ui.Rectangle{X: 550, Y: 150, Width: 500, Height: 300},
+		Style: ui.Style{
+			Ordering: ui.StyleOrderRow,
+			Padding:  2,
+			Margin:   ui.Point{2, 2},
+		},
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr1,
+		},
+		HasHeader:    true,
+		HeaderHeight: 20,
+		HeaderBackground: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundImageSlice,
+			Clr:     theme.dividerClr,
+			Img:     &ed.header,
+			Constr:  ui.Constraint{Left: 2, Right: 2, Up: 2, Down: 2},
+		},
+		HasHeaderTitle: true,
+		HeaderTitle:    "Open file",
+		HeaderFont:     &ed.font,
+		HeaderFontSize: 12,
+		HeaderFontClr:  theme.normalTextClr,
+
+		HasBorders:  true,
+		BorderWidth: 1,
+		BorderColor: theme.dividerClr,
+	})
+	q.window.SetCloseBtn(ui.Button{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+		},
+		UserID:       editorCloseBtn,
+		Clr:          theme.backgroundClr3,
+		HighlightClr: theme.backgroundClr3,
+		PressedClr:   theme.backgroundClr3,
+		HasIcon:      true,
+		Icon:         &ed.cross,
+		IconClr:      theme.backgroundClr1,
+		Receiver:     q,
+	})
+
+	q.queryBox = &ui.TextBox{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Cap:       200,
+		Margin:    3,
+		Font:      &ed.font,
+		TextSize:  12,
+		TextClr:   theme.normalTextClr,
+		Multiline: false,
+	}
+	q.resultList = &ui.ResultList{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Style: ui.Style{
+			Margin: ui.Point{3, 3},
+		},
+		Font:     &ed.font,
+		TextSize: 12,
+		TextClr:  theme.normalTextClr,
+		Receiver: q,
+	}
+	q.window.AddWidget(q.queryBox, 22)
+	q.window.AddWidget(q.resultList, ui.FitContainer)
+	q.window.UnfocusWindow()
+	AddSignalListener(EditorThemeChanged, q)
+}
+
+func (q *QuickOpen) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		q.window.SetColors(theme.windowColors())
+		q.queryBox.Background.Clr = theme.backgroundClr2
+		q.queryBox.TextClr = theme.normalTextClr
+		q.resultList.TextClr = theme.normalTextClr
+	}
+}
+
+func (q *QuickOpen) updateQuickOpen() {
+	if !q.window.IsActive() {
+		return
+	}
+
+	if query := string(q.queryBox.GetCharBuffer()); query != q.query {
+		q.rank(query)
+	}
+}
+
+func (q *QuickOpen) toggle() {
+	if q.window.IsActive() {
+		q.window.SetActive(false)
+	} else {
+		q.open()
+	}
+}
+
+func (q *QuickOpen) open() {
+	if ed.project.root == nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No project opened",
+		})
+		return
+	}
+	// The project can change between two uses
+	files := ed.project.files()
+	q.paths = q.paths[:0]
+	for _, f := range files {
+		q.paths = append(q.paths, ed.project.relativePath(f.path()))
+	}
+	q.queryBox.EmptyCharBuffer()
+	q.rank("")
+
+	q.window.SetActive(true)
+	q.queryBox.SetFocus(true)
+}
+
+func (q *QuickOpen) rank(query string) {
+	q.query = query
+	matches := fuzzy.Rank(query, q.paths, quickOpenMaxResults)
+	q.ranked = q.ranked[:0]
+	items := make([]string, len(matches))
+	for i, m := range matches {
+		q.ranked = append(q.ranked, m.Index)
+		items[i] = q.paths[m.Index]
+	}
+	q.resultList.SetItems(items)
+}
+
+func (q *QuickOpen) OnResultSelected(index int) {
+	if openProjectPath(q.paths[q.ranked[index]]) {
+		q.window.SetActive(false)
+	}
+}
+
+func (q *QuickOpen) OnButtonPressed(w ui.Widget, id ui.ButtonID) {
+	q.window.SetActive(false)
+}
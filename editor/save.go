@@ -0,0 +1,87 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The mode of the new files
+const newFilePerm = 0644
+
+// Replace the content of the file at <path> with <data>. It is written
+// to a temporary file next to it, synced, then renamed over it, so a
+// crash leaves either the old or the new content. The permissions of
+// the file are kept, and with <backup> its old content is copied to
+// <path>.bak first
+func writeFileAtomic(path string, data []byte, backup bool) error {
+	// The link is kept, its target is replaced
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	perm := os.FileMode(newFilePerm)
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+		if backup {
+			if err := copyFile(path, path+".bak", perm); err != nil {
+				return fmt.Errorf("backup failed: %w", err)
+			}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	dir, name := filepath.Split(path)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Nothing to clean up once renamed
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	renamed = true
+	syncDir(dir)
+	return nil
+}
+
+// Make a rename in <dir> durable. Not every system
+// can sync a folder, so it's only attempted
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, perm)
+}
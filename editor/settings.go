@@ -0,0 +1,294 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/nico-ec/uwu/toml"
+)
+
+const (
+	// The user settings, next to the assets
+	userSettingsPath = "editor.toml"
+	// The settings of a project, in its root folder
+	projectSettingsName = ".uwu.toml"
+)
+
+// The built-in settings, overridden by the user
+// file and then by the one of the project:
+//
+//	[editor]
+//	tab_size = 2
+//	auto_indent = true
+//	ruler = true
+//	highlight_current_line = true
+//	autocomplete = true
+//	format_on_save = true
+//	backup_on_save = false
+//
+//	[font]
+//	path = "assets/CozetteVector.ttf"
+//	size = 12
+//
+//	[window]
+//	width = 1600
+//	height = 900
+//	tps = 30
+//
+//	[lsp]
+//	go = "gopls"
+//
+//	[format]
+//	# .go files are formatted with go/format unless set here
+//	js = "prettier --stdin-filepath file.js"
+var defaultSettings = settings{
+	tabSize:      2,
+	autoIndent:   true,
+	ruler:        true,
+	currentLine:  true,
+	autoComplete: true,
+	formatOnSave: true,
+
+	fontPath: "assets/CozetteVector.ttf",
+	fontSize: 12,
+
+	windowWidth:  1600,
+	windowHeight: 900,
+	tps:          30,
+
+	languageServers: map[string]string{
+		"go": "gopls",
+	},
+	formatters: map[string]string{},
+}
+
+type (
+	settings struct {
+		tabSize     int
+		autoIndent  bool
+		ruler       bool
+		currentLine bool
+		// Open the completions while typing, not only on demand
+		autoComplete bool
+		formatOnSave bool
+		// Keep the previous content of a saved file as <name>.bak
+		backupOnSave bool
+
+		// The font file and the window are only set up at start-up
+		fontPath string
+		fontSize int
+
+		windowWidth  int
+		windowHeight int
+		tps          int
+
+		// The command starting the language server of each file
+		// extension. An empty command disables the server
+		languageServers map[string]string
+		// The command formatting the files of each extension,
+		// reading them on stdin and writing the result on stdout
+		formatters map[string]string
+	}
+
+	// An integer setting and its valid range
+	intSetting struct {
+		value    *int
+		min, max int
+	}
+)
+
+// The fields of <s> by "table.key"
+func (s *settings) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"editor.tab_size":               intSetting{&s.tabSize, 1, 16},
+		"editor.auto_indent":            &s.autoIndent,
+		"editor.ruler":                  &s.ruler,
+		"editor.highlight_current_line": &s.currentLine,
+		"editor.autocomplete":           &s.autoComplete,
+		"editor.format_on_save":         &s.formatOnSave,
+		"editor.backup_on_save":         &s.backupOnSave,
+		"font.path":                     &s.fontPath,
+		"font.size":                     intSetting{&s.fontSize, 6, 72},
+		"window.width":                  intSetting{&s.windowWidth, 320, 7680},
+		"window.height":                 intSetting{&s.windowHeight, 240, 4320},
+		"window.tps":                    intSetting{&s.tps, 1, 240},
+	}
+}
+
+// Apply the settings found in the TOML <source> over <s>. An invalid
+// value is skipped and reported, the other ones are still applied
+func (s *settings) merge(source string) []error {
+	root, err := toml.Parse(source)
+	if err != nil {
+		return []error{err}
+	}
+	fields := s.fields()
+	var errs []error
+	for _, tableName := range sortedKeys(root) {
+		table, ok := root[tableName].(toml.Table)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting %s", tableName))
+			continue
+		}
+		switch tableName {
+		case "lsp":
+			var tableErrs []error
+			s.languageServers, tableErrs = mergeCommands(tableName, s.languageServers, table)
+			errs = append(errs, tableErrs...)
+			continue
+		case "format":
+			var tableErrs []error
+			s.formatters, tableErrs = mergeCommands(tableName, s.formatters, table)
+			errs = append(errs, tableErrs...)
+			continue
+		}
+		for _, key := range sortedKeys(table) {
+			name := tableName + "." + key
+			if err := setField(fields[name], name, table[key]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// The keys of the lsp and format tables are file extensions, so they
+// can't be listed by fields. The commands of <table> are added to a
+// copy of <commands>
+func mergeCommands(tableName string, commands map[string]string, table toml.Table) (map[string]string, []error) {
+	// The map is shared with the settings it was copied from
+	merged := make(map[string]string, len(commands))
+	for ext, command := range commands {
+		merged[ext] = command
+	}
+	var errs []error
+	for _, ext := range sortedKeys(table) {
+		command, ok := table[ext].(toml.String)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.%s should be a string", tableName, ext))
+			continue
+		}
+		merged[strings.ToLower(strings.TrimPrefix(ext, "."))] = string(command)
+	}
+	return merged, errs
+}
+
+func setField(field interface{}, name string, value toml.Value) error {
+	switch f := field.(type) {
+	case nil:
+		return fmt.Errorf("unknown setting %s", name)
+	case *bool:
+		b, ok := value.(toml.Boolean)
+		if !ok {
+			return fmt.Errorf("%s should be true or false", name)
+		}
+		*f = bool(b)
+	case *string:
+		s, ok := value.(toml.String)
+		if !ok {
+			return fmt.Errorf("%s should be a string", name)
+		}
+		*f = string(s)
+	case intSetting:
+		n, ok := value.(toml.Number)
+		if !ok || n != toml.Number(int(n)) || int(n) < f.min || int(n) > f.max {
+			return fmt.Errorf("%s should be an integer from %d to %d", name, f.min, f.max)
+		}
+		*f.value = int(n)
+	}
+	return nil
+}
+
+func sortedKeys(t toml.Table) []string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Merge the user file and the one of the project at <projectRoot>
+// over the defaults. <projectRoot> is empty if no project is opened
+func loadSettings(projectRoot string) (settings, []error) {
+	s := defaultSettings
+	var errs []error
+	paths := []string{userSettingsPath}
+	if projectRoot != "" {
+		paths = append(paths, filepath.Join(projectRoot, projectSettingsName))
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, err := range s.merge(string(data)) {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+		}
+	}
+	if _, err := os.Stat(s.fontPath); err != nil {
+		errs = append(errs, fmt.Errorf("font.path: %w", err))
+		s.fontPath = defaultSettings.fontPath
+	}
+	return s, errs
+}
+
+// Check if the file at <path> is one of the settings files
+func isSettingsFile(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if user, err := filepath.Abs(userSettingsPath); err == nil && path == user {
+		return true
+	}
+	if ed.project.root == nil {
+		return false
+	}
+	proj, err := filepath.Abs(filepath.Join(ed.project.root.nodePath, projectSettingsName))
+	return err == nil && path == proj
+}
+
+// Read the settings files again, the opened files pick the
+// changes up from EditorSettingsChanged
+func reloadSettings() {
+	root := ""
+	if ed.project.root != nil {
+		root = ed.project.root.nodePath
+	}
+	s, errs := loadSettings(root)
+	reportSettingsErrors(errs)
+
+	previous := ed.settings
+	if s.fontPath != previous.fontPath || s.windowWidth != previous.windowWidth ||
+		s.windowHeight != previous.windowHeight {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "The font path and the window size are applied on restart",
+		})
+		s.fontPath = previous.fontPath
+		s.windowWidth, s.windowHeight = previous.windowWidth, previous.windowHeight
+	}
+	ed.font.addSize(s.fontSize)
+	ed.settings = s
+	ebiten.SetMaxTPS(s.tps)
+	// The listeners read the new values from ed.settings
+	FireSignal(EditorSettingsChanged, nil)
+}
+
+func reportSettingsErrors(errs []error) {
+	for _, err := range errs {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Settings error in " + err.Error(),
+		})
+	}
+}
@@ -10,10 +10,13 @@ import (
 type statusBar struct {
 	statusLayout *ui.Layout
 
-	lineLabel *ui.Label
-	colLabel  *ui.Label
-	errIcon   *ui.Icon
-	errLabel  *ui.Label
+	lineLabel   *ui.Label
+	colLabel    *ui.Label
+	endingLabel *ui.Label
+	searchLabel *ui.Label
+	diagLabel   *ui.Label
+	errIcon     *ui.Icon
+	errLabel    *ui.Label
 
 	errorRaisedRecently bool
 	errorTimer          int
@@ -52,6 +55,33 @@ func newStatusBar(parent ui.Container, font *Font) statusBar {
 			Clr:  theme.normalTextClr2,
 			Size: 12,
 		},
+		endingLabel: &ui.Label{
+			Background: ui.Background{
+				Visible: false,
+			},
+			Font: font,
+			Text: "",
+			Clr:  theme.normalTextClr2,
+			Size: 12,
+		},
+		searchLabel: &ui.Label{
+			Background: ui.Background{
+				Visible: false,
+			},
+			Font: font,
+			Text: "",
+			Clr:  theme.normalTextClr2,
+			Size: 12,
+		},
+		diagLabel: &ui.Label{
+			Background: ui.Background{
+				Visible: false,
+			},
+			Font: font,
+			Text: "",
+			Clr:  theme.normalTextClr2,
+			Size: 12,
+		},
 		errIcon: &ui.Icon{},
 		errLabel: &ui.Label{
 			Background: ui.Background{
@@ -67,6 +97,9 @@ func newStatusBar(parent ui.Container, font *Font) statusBar {
 	parent.AddWidget(s.statusLayout, ui.FitContainer) // 20 units I think
 	s.statusLayout.AddWidget(s.lineLabel, int(font.MeasureText("line: 0000", 12)[0]))
 	s.statusLayout.AddWidget(s.colLabel, int(font.MeasureText("column: 0000", 12)[0]))
+	s.statusLayout.AddWidget(s.endingLabel, int(font.MeasureText("CRLF", 12)[0]))
+	s.statusLayout.AddWidget(s.searchLabel, int(font.MeasureText("match 0000 of 0000", 12)[0]))
+	s.statusLayout.AddWidget(s.diagLabel, int(font.MeasureText("000 errors, 000 warnings", 12)[0]))
 	s.statusLayout.AddWidget(s.errIcon, 20)
 	s.statusLayout.AddWidget(s.errLabel, ui.FitContainer)
 
@@ -77,6 +110,10 @@ func (s *statusBar) initStatusBar() {
 	AddSignalListener(EditorLineChanged, s)
 	AddSignalListener(EditorColumnChanged, s)
 	AddSignalListener(EditorErrorRaised, s)
+	AddSignalListener(EditorLineEndingChanged, s)
+	AddSignalListener(EditorSearchMatchesChanged, s)
+	AddSignalListener(EditorThemeChanged, s)
+	AddSignalListener(EditorDiagnosticsChanged, s)
 }
 
 func (s *statusBar) updateStatusBar() {
@@ -102,6 +139,18 @@ func (s *statusBar) OnSignal(signal Signal) {
 		s.colLabel.SetText(
 			fmt.Sprintf("column: %d", signal.Value),
 		)
+	case EditorLineEndingChanged:
+		s.endingLabel.SetText(signal.Value.ToString())
+	case EditorSearchMatchesChanged:
+		s.searchLabel.SetText(searchMatchesText(signal.Value.(SignalArray)))
+	case EditorDiagnosticsChanged:
+		s.diagLabel.SetText(ed.lsp.diagnosticsSummary())
+	case EditorThemeChanged:
+		theme := getTheme()
+		s.statusLayout.Background.Clr = theme.backgroundClr3
+		for _, label := range []*ui.Label{s.lineLabel, s.colLabel, s.endingLabel, s.searchLabel, s.diagLabel, s.errLabel} {
+			label.Clr = theme.normalTextClr2
+		}
 
 	case EditorErrorRaised:
 		err := signal.Value.(SignalError)
@@ -118,3 +167,21 @@ func (s *statusBar) OnSignal(signal Signal) {
 		s.errorDuration = ebiten.MaxTPS() * 5
 	}
 }
+
+// The match count sent by the find panel is [current, total],
+// a negative total means the pattern is invalid
+func searchMatchesText(count SignalArray) string {
+	if len(count) != 2 {
+		return ""
+	}
+	current, total := count[0].(SignalInt), count[1].(SignalInt)
+	switch {
+	case total < 0:
+		return "invalid pattern"
+	case total == 0:
+		return "no match"
+	case current == 0:
+		return fmt.Sprintf("%d matches", total)
+	}
+	return fmt.Sprintf("match %d of %d", current, total)
+}
@@ -3,24 +3,36 @@ package editor
 import (
 	"bytes"
 	"fmt"
-	"io/fs"
 	"os"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nico-ec/uwu/clipboard"
+	"github.com/nico-ec/uwu/syntax"
 	"github.com/nico-ec/uwu/ui"
 )
 
 const initialAddedBufferCap = 200
 
-type textEditor struct {
-	currentEdit projectNode
-	tabViewer   *ui.TabViewer
-	// textBox        *ui.TextBox
-	previousLine   int
-	previousColumn int
-}
+type (
+	textEditor struct {
+		tabViewer *ui.TabViewer
+		files     map[*ui.TextBox]*editedFile
+		// textBox        *ui.TextBox
+		previousTab    *ui.TextBox
+		previousLine   int
+		previousColumn int
+
+		// The files a command is formatting, and the
+		// results to apply once it is done
+		formatting    map[*ui.TextBox]bool
+		formatResults chan func()
+	}
+
+	// A file opened in one of the tabs
+	editedFile struct {
+		node   projectNode
+		ending lineEnding
+	}
+)
 
 func newTextEditor(parent ui.Container) textEditor {
 	theme := getTheme()
@@ -39,6 +51,9 @@ func newTextEditor(parent ui.Container) textEditor {
 			TabBckgroundClr: theme.backgroundClr3,
 			TabFontClr:      theme.normalTextClr2,
 		},
+		files:         make(map[*ui.TextBox]*editedFile),
+		formatting:    make(map[*ui.TextBox]bool),
+		formatResults: make(chan func(), 16),
 	}
 	parent.AddWidget(textEd.tabViewer, ui.FitContainer)
 
@@ -47,11 +62,19 @@ func newTextEditor(parent ui.Container) textEditor {
 
 // Extend the features of ui.TextBox and handle more input kind
 func (t *textEditor) updateTextEditor() {
+	t.updateFormatters()
 	textBox, ok := t.tabViewer.ActiveTab().(*ui.TextBox)
 	if !ok {
 		return
 	}
 
+	if textBox != t.previousTab {
+		if file, exist := t.files[textBox]; exist {
+			FireSignal(EditorLineEndingChanged, SignalString(file.ending.String()))
+		}
+		t.previousTab = textBox
+	}
+
 	// Check if line or column changed and fire signal
 	ln, col := textBox.CurrentLine(), textBox.CurrentColumn()
 	switch {
@@ -63,115 +86,211 @@ func (t *textEditor) updateTextEditor() {
 		FireSignal(EditorColumnChanged, SignalInt(col))
 		t.previousColumn = col
 	}
+}
 
-	// Advanced input handling that textbox doesn't handle
-	if ebiten.IsKeyPressed(ebiten.KeyControl) {
-		switch {
-		case inpututil.IsKeyJustPressed(ebiten.KeyS):
-			t.saveNode()
-		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyEnd) {
-		textBox.MoveCursorLineEnd()
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyHome) {
-		textBox.MoveCursorLineStart()
+// The TextBox of the active tab and the file it edits
+func (t *textEditor) activeFile() (*ui.TextBox, *editedFile) {
+	textBox, ok := t.tabViewer.ActiveTab().(*ui.TextBox)
+	if !ok {
+		return nil, nil
+	}
+	return textBox, t.files[textBox]
+}
+
+// Move the caret of the active file to <line> (starting at 1) and <col>,
+// and give it the focus. Both are clamped to the content of the file
+func (t *textEditor) jumpTo(line, col int) {
+	textBox, _ := t.activeFile()
+	if textBox == nil {
+		return
 	}
+	textBox.JumpTo(line, col)
+	textBox.SetFocus(true)
 }
 
 func (t *textEditor) saveNode() {
-	if t.currentEdit == nil {
+	textBox, file := t.activeFile()
+	if file == nil {
 		return
 	}
-	textBox, ok := t.tabViewer.ActiveTab().(*ui.TextBox)
-	if !ok {
+
+	if ed.settings.formatOnSave {
+		// Saved once formatted, or not
+		t.formatFile(textBox, file, func() {
+			t.writeFile(textBox, file)
+		})
 		return
 	}
+	t.writeFile(textBox, file)
+}
 
-	path := t.currentEdit.path()
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fs.ModeExclusive)
-	if err != nil {
-		panic(err)
+// Write the content of <textBox> to <file>
+func (t *textEditor) writeFile(textBox *ui.TextBox, file *editedFile) {
+	path := file.node.path()
+	data := applyLineEnding(string(textBox.GetCharBuffer()), file.ending)
+	if err := writeFileAtomic(path, []byte(data), ed.settings.backupOnSave); err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  fmt.Sprintf("Could not save %s: %s", file.node.name(), err),
+		})
+		return
 	}
-	defer file.Close()
-	buf := textBox.GetCharBuffer()
-	fmt.Println(buf)
-	_, err = file.WriteString(string(textBox.GetCharBuffer()))
-	if err != nil {
-		panic(err)
+	ed.lsp.saved(textBox)
+	if isSettingsFile(path) {
+		reloadSettings()
 	}
-
 }
 
-func (t *textEditor) loadNode(node projectNode) {
+// Open <node> in a new tab, or show its tab if it is already
+// opened. Return false if the file couldn't be read
+func (t *textEditor) loadNode(node projectNode) bool {
+	if textBox := t.findOpened(node); textBox != nil {
+		t.tabViewer.SetActiveWidget(textBox)
+		return true
+	}
 	data, err := os.ReadFile(node.path())
 	if err != nil {
-		panic(err)
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  fmt.Sprintf("Could not open %s: %s", node.name(), err),
+		})
+		return false
 	}
 	d := bytes.Runes(data)
-	t.currentEdit = node
 	name := node.name()
+	// Files sharing the same name are told apart by their path
+	if t.tabViewer.ContainsTab(name) {
+		name = ed.project.relativePath(node.path())
+	}
 
-	if !t.tabViewer.ContainsTab(name) {
+	theme := getTheme()
+	textBox := &ui.TextBox{
+		Background: ui.Background{
+			Visible: false,
+		},
+		Cap:                len(d) + initialAddedBufferCap,
+		Margin:             10,
+		Font:               &ed.font,
+		TextSize:           float64(ed.settings.fontSize),
+		TabSize:            ed.settings.tabSize,
+		AutoIndent:         ed.settings.autoIndent,
+		Multiline:          true,
+		HasRuler:           ed.settings.ruler,
+		HasScrollbar:       true,
+		HasSyntaxHighlight: true,
+		ShowCurrentLine:    ed.settings.currentLine,
+	}
+	// The language is picked from the extension,
+	// files without a known one are left uncolored
+	if lexer := syntax.ForFile(name); lexer != nil {
+		textBox.SetHighlighter(lexer)
+	}
+	textBox.SetSyntaxColors(theme.syntaxStyle())
+	textBox.SetDiagnosticStyle(theme.diagnosticStyle())
+	textBox.SetClipboardCallback(t)
+	textBox.SetCompletion(&ui.CompletionPopup{
+		Background: ui.Background{
+			Visible: true,
+			Kind:    ui.BackgroundSolidColor,
+			Clr:     theme.backgroundClr2,
+		},
+		Provider:  &serverCompletion{},
+		MinPrefix: completionMinPrefix(),
+	})
+	t.tabViewer.AddTab(name, textBox)
+	textBox.LoadBufferData(d)
+
+	ending, mixed := detectLineEnding(data)
+	if mixed {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  fmt.Sprintf("Mixed line endings in %s, it will be saved with %s", name, ending),
+		})
+	}
+	t.files[textBox] = &editedFile{
+		node:   node,
+		ending: ending,
+	}
+	ed.lsp.open(textBox, node.path())
+	return true
+}
+
+func (t *textEditor) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
 		theme := getTheme()
-		textBox := &ui.TextBox{
-			Background: ui.Background{
-				Visible: false,
-			},
-			Cap:                len(d) + initialAddedBufferCap,
-			Margin:             10,
-			Font:               &ed.font,
-			TextSize:           12,
-			TabSize:            2,
-			AutoIndent:         true,
-			Multiline:          true,
-			HasRuler:           true,
-			HasSyntaxHighlight: true,
-			ShowCurrentLine:    true,
+		t.tabViewer.HeaderBackground.Clr = theme.dividerClr
+		t.tabViewer.TabBckgroundClr = theme.backgroundClr3
+		t.tabViewer.TabFontClr = theme.normalTextClr2
+		for textBox := range t.files {
+			textBox.SetSyntaxColors(theme.syntaxStyle())
+			textBox.SetDiagnosticStyle(theme.diagnosticStyle())
+			textBox.Completion().Background.Clr = theme.backgroundClr2
 		}
-		// Temporary. Those are go keywords
-		// Allow for user to set their prefered
-		// language from a given .toml file
-		textBox.SetLexKeywords([]string{
-			"type",
-			"struct",
-			"interface",
-			"func",
-			"go",
-			"return",
-			"bool",
-			"uint",
-			"uint8",
-			"uint16",
-			"uint32",
-			"uint64",
-			"int",
-			"int8",
-			"int16",
-			"int32",
-			"int64",
-			"float64",
-			"float32",
-		})
-		textBox.SetSyntaxColors(ui.ColorStyle{
-			Normal:  theme.syntaxNormalClr,
-			Keyword: theme.syntaxKeywordClr,
-			Digit:   theme.syntaxNumberClr,
+	case EditorSettingsChanged:
+		// The font size and the ruler change the layout
+		// of a TextBox, only the new ones use them
+		for textBox := range t.files {
+			textBox.SetTabSize(ed.settings.tabSize)
+			textBox.AutoIndent = ed.settings.autoIndent
+			textBox.ShowCurrentLine = ed.settings.currentLine
+			textBox.Completion().MinPrefix = completionMinPrefix()
+		}
+	}
+}
+
+// The runes of a word typed before the completions show up,
+// 0 if they only open with the autocomplete command
+func completionMinPrefix() int {
+	if ed.settings.autoComplete {
+		return 2
+	}
+	return 0
+}
+
+// The TextBox editing <node> if it's already opened
+func (t *textEditor) findOpened(node projectNode) *ui.TextBox {
+	for textBox, file := range t.files {
+		if file.node.path() == node.path() {
+			return textBox
+		}
+	}
+	return nil
+}
+
+// Change the line terminator style used
+// when the active file is saved
+func (t *textEditor) setLineEnding(e lineEnding) {
+	_, file := t.activeFile()
+	if file == nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "No file opened",
 		})
-		textBox.SetClipboardCallback(t)
-		t.tabViewer.AddTab(name, textBox)
-		textBox.LoadBufferData(d)
-	} else {
-		t.tabViewer.SetActiveTab(name)
+		return
 	}
+	file.ending = e
+	FireSignal(EditorLineEndingChanged, SignalString(e.String()))
 }
 
 func (t *textEditor) ReadClipboard() string {
 	data, err := clipboard.ReadClipboard()
 	if err != nil {
-		panic(err)
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorWarning,
+			Msg:  "Could not read the clipboard: " + err.Error(),
+		})
+		return ""
 	}
 	return data
 }
 
 func (t *textEditor) WriteClipboard(s string) {
-	panic("Writing to clipboard not implemented yet")
+	err := clipboard.WriteClipboard(s)
+	if err != nil {
+		FireSignal(EditorErrorRaised, SignalError{
+			Kind: editorError,
+			Msg:  "Could not write to the clipboard: " + err.Error(),
+		})
+	}
 }
@@ -1,19 +1,50 @@
 package editor
 
-import "github.com/nico-ec/uwu/ui"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nico-ec/uwu/toml"
+	"github.com/nico-ec/uwu/ui"
+)
+
+// The theme files, next to the assets
+const (
+	themesDir        = "themes"
+	defaultThemeName = "light"
+)
 
 var (
+	// Used when the theme files can't be loaded
 	lightTheme = theme{
 		backgroundClr1: ui.Color{247, 231, 230, 255},
 		backgroundClr2: ui.Color{247, 231, 230, 255},
 		backgroundClr3: ui.Color{255, 95, 131, 255},
 		dividerClr:     ui.Color{255, 95, 131, 255},
+		rulerClr:       ui.Color{255, 95, 131, 255},
 		normalTextClr:  ui.Color{255, 95, 131, 255},
 		normalTextClr2: ui.Color{247, 231, 230, 255},
 
-		syntaxKeywordClr: ui.Color{200, 106, 255, 255},
-		syntaxNormalClr:  ui.Color{255, 95, 131, 255},
-		syntaxNumberClr:  ui.Color{213, 133, 128, 255},
+		syntaxNormalClr:   ui.Color{255, 95, 131, 255},
+		syntaxSymbolClr:   ui.Color{232, 120, 150, 255},
+		syntaxCommentClr:  ui.Color{190, 160, 170, 255},
+		syntaxKeywordClr:  ui.Color{200, 106, 255, 255},
+		syntaxTypeClr:     ui.Color{86, 150, 220, 255},
+		syntaxFunctionClr: ui.Color{230, 110, 60, 255},
+		syntaxNumberClr:   ui.Color{213, 133, 128, 255},
+		syntaxStringClr:   ui.Color{70, 160, 130, 255},
+
+		errorClr:   ui.Color{220, 50, 60, 255},
+		warningClr: ui.Color{225, 150, 30, 255},
+		infoClr:    ui.Color{86, 150, 220, 255},
+	}
+
+	// The themes :theme can switch to, by file name
+	themes = map[string]theme{
+		defaultThemeName: lightTheme,
 	}
 )
 
@@ -30,7 +61,191 @@ type theme struct {
 	syntaxSymbolClr   ui.Color
 	syntaxCommentClr  ui.Color
 	syntaxKeywordClr  ui.Color
+	syntaxTypeClr     ui.Color
 	syntaxFunctionClr ui.Color
 	syntaxNumberClr   ui.Color
 	syntaxStringClr   ui.Color
+
+	// The underlines of the diagnostics
+	errorClr   ui.Color
+	warningClr ui.Color
+	infoClr    ui.Color
+}
+
+func (t theme) syntaxStyle() ui.ColorStyle {
+	return ui.ColorStyle{
+		Normal:   t.syntaxNormalClr,
+		Keyword:  t.syntaxKeywordClr,
+		Type:     t.syntaxTypeClr,
+		Function: t.syntaxFunctionClr,
+		Digit:    t.syntaxNumberClr,
+		String:   t.syntaxStringClr,
+		Comment:  t.syntaxCommentClr,
+		Symbol:   t.syntaxSymbolClr,
+	}
+}
+
+func (t theme) diagnosticStyle() ui.DiagnosticStyle {
+	return ui.DiagnosticStyle{
+		Error:       t.errorClr,
+		Warning:     t.warningClr,
+		Info:        t.infoClr,
+		ErrorIcon:   &ed.err,
+		WarningIcon: &ed.warning,
+		Tooltip:     t.backgroundClr2,
+	}
+}
+
+// The colors shared by all the windows of the editor
+func (t theme) windowColors() ui.WindowColors {
+	return ui.WindowColors{
+		Background: t.backgroundClr1,
+		Header:     t.dividerClr,
+		HeaderFont: t.normalTextClr,
+		Border:     t.dividerClr,
+		Button:     t.backgroundClr3,
+		ButtonIcon: t.backgroundClr1,
+	}
+}
+
+// Add the themes (*.toml) found in <dir>, named after their file.
+// A file that can't be loaded is skipped and its error returned
+func loadThemes(dir string) []error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t, err := parseTheme(string(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		themes[strings.ToLower(name)] = t
+	}
+	return errs
+}
+
+// Read a theme from the content of a TOML file. Every color is
+// required, either as "#RRGGBB", "#RRGGBBAA" or [r, g, b(, a)]:
+//
+//	[ui]
+//	background1 = "#F7E7E6" # The text area
+//	background2 = "#F7E7E6" # The treeview and the text boxes of the panels
+//	background3 = "#FF5F83" # The statusbar, the selected tab and the buttons
+//	divider = "#FF5F83"
+//	ruler = "#FF5F83"
+//	text = "#FF5F83"
+//	text2 = "#F7E7E6"       # The text drawn over background3
+//
+//	[syntax]
+//	normal = [255, 95, 131]
+//	symbol = ...
+//	comment, keyword, type, function, number, string
+//
+// The [diagnostics] table (error, warning, info) is optional,
+// the colors of the default theme are used without it
+func parseTheme(source string) (theme, error) {
+	root, err := toml.Parse(source)
+	if err != nil {
+		return theme{}, err
+	}
+	d := themeDecoder{}
+	colors := d.table(root, "ui")
+	syntax := d.table(root, "syntax")
+	t := theme{
+		backgroundClr1: d.color(colors, "ui", "background1"),
+		backgroundClr2: d.color(colors, "ui", "background2"),
+		backgroundClr3: d.color(colors, "ui", "background3"),
+		dividerClr:     d.color(colors, "ui", "divider"),
+		rulerClr:       d.color(colors, "ui", "ruler"),
+		normalTextClr:  d.color(colors, "ui", "text"),
+		normalTextClr2: d.color(colors, "ui", "text2"),
+
+		syntaxNormalClr:   d.color(syntax, "syntax", "normal"),
+		syntaxSymbolClr:   d.color(syntax, "syntax", "symbol"),
+		syntaxCommentClr:  d.color(syntax, "syntax", "comment"),
+		syntaxKeywordClr:  d.color(syntax, "syntax", "keyword"),
+		syntaxTypeClr:     d.color(syntax, "syntax", "type"),
+		syntaxFunctionClr: d.color(syntax, "syntax", "function"),
+		syntaxNumberClr:   d.color(syntax, "syntax", "number"),
+		syntaxStringClr:   d.color(syntax, "syntax", "string"),
+
+		errorClr:   lightTheme.errorClr,
+		warningClr: lightTheme.warningClr,
+		infoClr:    lightTheme.infoClr,
+	}
+	if diagnostics, ok := root["diagnostics"].(toml.Table); ok {
+		t.errorClr = d.color(diagnostics, "diagnostics", "error")
+		t.warningClr = d.color(diagnostics, "diagnostics", "warning")
+		t.infoClr = d.color(diagnostics, "diagnostics", "info")
+	}
+	return t, d.err
+}
+
+// Keep the first error found while reading a theme
+type themeDecoder struct {
+	err error
+}
+
+func (d *themeDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *themeDecoder) table(t toml.Table, key string) toml.Table {
+	table, ok := t[key].(toml.Table)
+	if !ok {
+		d.fail("missing table [%s]", key)
+	}
+	return table
+}
+
+func (d *themeDecoder) color(t toml.Table, table string, key string) ui.Color {
+	switch value := t[key].(type) {
+	case nil:
+		d.fail("missing color %s.%s", table, key)
+	case toml.String:
+		clr, err := ui.ParseHexColor(string(value))
+		if err != nil {
+			d.fail("%s.%s: %w", table, key, err)
+		}
+		return clr
+	case *toml.Array:
+		clr := ui.Color{0, 0, 0, 255}
+		if value.Len() != 3 && value.Len() != 4 {
+			d.fail("%s.%s should be [r, g, b] or [r, g, b, a]", table, key)
+			return clr
+		}
+		for i := 0; i < value.Len(); i += 1 {
+			c, ok := value.Get(i).(toml.Number)
+			if !ok || c < 0 || c > 255 || c != toml.Number(int(c)) {
+				d.fail("%s.%s should only hold integers from 0 to 255", table, key)
+				return clr
+			}
+			clr[i] = uint8(c)
+		}
+		return clr
+	default:
+		d.fail("%s.%s should be a \"#RRGGBB\" string or an [r, g, b] array", table, key)
+	}
+	return ui.Color{}
+}
+
+// Names of the loaded themes, sorted
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -10,11 +10,15 @@ const (
 
 type treeview struct {
 	list *ui.List
+	// Names aren't unique, so the items remember their file
+	paths map[ui.ListNode]string
 }
 
 func newTreeview(parent ui.Container, sepImg *Image, font *Font) treeview {
 	theme := getTheme()
-	treeview := treeview{}
+	treeview := treeview{
+		paths: make(map[ui.ListNode]string),
+	}
 	treeview.list = &ui.List{
 		Background: ui.Background{
 			Visible: true,
@@ -46,28 +50,43 @@ func (t *treeview) loadProject(p *project) {
 		case *folder:
 			subList := ui.NewSubList(k)
 			t.list.AddItem(&subList)
-			populateSubList(&subList, f)
+			t.populateSubList(&subList, f)
 		case file:
-			t.list.AddItem(&ui.ListItem{
+			item := &ui.ListItem{
 				ItemName:       k,
 				ItemIcon:       &ed.file,
 				ItemIconOffset: 1,
-			})
+			}
+			t.paths[item] = f.path()
+			t.list.AddItem(item)
 		}
 	}
 	t.list.SortList()
 }
 
 func (t *treeview) OnItemSelected(item ui.ListNode) {
-	openProjectFile(item.Name())
+	if path, exist := t.paths[item]; exist {
+		openProjectPath(path)
+	} else {
+		openProjectFile(item.Name())
+	}
+}
+
+func (t *treeview) OnSignal(s Signal) {
+	switch s.Kind {
+	case EditorThemeChanged:
+		theme := getTheme()
+		t.list.Background.Clr = theme.dividerClr
+		t.list.TextClr = theme.normalTextClr
+	}
 }
 
-func populateSubList(l *ui.SubList, f *folder) {
+func (t *treeview) populateSubList(l *ui.SubList, f *folder) {
 	for k, v := range f.nodes {
 		switch f := v.(type) {
 		case *folder:
 			subList := ui.NewSubList(k)
-			populateSubList(&subList, f)
+			t.populateSubList(&subList, f)
 			l.AddItem(&subList, 10, 12)
 		case file:
 			item := &ui.ListItem{
@@ -75,6 +94,7 @@ func populateSubList(l *ui.SubList, f *folder) {
 				ItemIcon:       &ed.file,
 				ItemIconOffset: 1,
 			}
+			t.paths[item] = f.path()
 			l.AddItem(item, 10, 12)
 		}
 	}
@@ -0,0 +1,162 @@
+// Package fuzzy scores strings against a pattern whose characters
+// must all appear in order, but not necessarily next to each other.
+// It's meant for file paths, so matches at the start of a path
+// segment or a word and in the file name are worth more.
+package fuzzy
+
+import (
+	"sort"
+	"unicode"
+)
+
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 12
+	bonusBoundary    = 10
+	bonusCamelCase   = 8
+	bonusBaseName    = 4
+	penaltyGap       = 1
+
+	noMatch = -1 << 30
+)
+
+type Match struct {
+	Index int
+	Score int
+}
+
+// Score how well <target> matches <pattern>, ignoring case.
+// The second value is false if <target> doesn't contain all
+// the characters of <pattern> in order
+func Score(pattern, target string) (int, bool) {
+	p := []rune(pattern)
+	t := []rune(target)
+	for i := range p {
+		p[i] = unicode.ToLower(p[i])
+	}
+	if len(p) == 0 {
+		return 0, true
+	}
+	if !isSubsequence(p, t) {
+		return 0, false
+	}
+
+	// Dynamic programming over the pattern. <prev>[j] is the best
+	// score of the pattern up to the previous rune with that rune
+	// matched at target[j]
+	base := baseNameStart(t)
+	prev := make([]int, len(t))
+	cur := make([]int, len(t))
+	for i, pr := range p {
+		// Best score of a match of the previous rune before j-1,
+		// minus the penalty of the gap up to j
+		run := noMatch
+		for j, tr := range t {
+			if i > 0 && j >= 2 {
+				run = maxInt(run, prev[j-2]) - penaltyGap
+			}
+			cur[j] = noMatch
+			if unicode.ToLower(tr) != pr {
+				continue
+			}
+			from := 0
+			if i > 0 {
+				from = run
+				if j >= 1 && prev[j-1] > noMatch {
+					from = maxInt(from, prev[j-1]+bonusConsecutive)
+				}
+				if from <= noMatch/2 {
+					continue
+				}
+			}
+			cur[j] = from + scoreMatch + bonusAt(t, j, base)
+		}
+		prev, cur = cur, prev
+	}
+
+	best := noMatch
+	for _, s := range prev {
+		best = maxInt(best, s)
+	}
+	return best, best > noMatch/2
+}
+
+// Return the targets matching <pattern>, the best first.
+// Equal scores are ordered by length then alphabetically.
+// At most <max> matches are returned when <max> is positive
+func Rank(pattern string, targets []string, max int) []Match {
+	matches := make([]Match, 0, len(targets))
+	for i, t := range targets {
+		if score, ok := Score(pattern, t); ok {
+			matches = append(matches, Match{Index: i, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		ta, tb := targets[a.Index], targets[b.Index]
+		if len(ta) != len(tb) {
+			return len(ta) < len(tb)
+		}
+		return ta < tb
+	})
+	if max > 0 && len(matches) > max {
+		matches = matches[:max]
+	}
+	return matches
+}
+
+func isSubsequence(pattern, target []rune) bool {
+	i := 0
+	for _, r := range target {
+		if i < len(pattern) && unicode.ToLower(r) == pattern[i] {
+			i += 1
+		}
+	}
+	return i == len(pattern)
+}
+
+func bonusAt(t []rune, j int, base int) int {
+	bonus := 0
+	if j >= base {
+		bonus += bonusBaseName
+	}
+	if j == 0 {
+		return bonus + bonusBoundary
+	}
+	previous, current := t[j-1], t[j]
+	switch {
+	case isSeparator(previous):
+		bonus += bonusBoundary
+	case unicode.IsLower(previous) && unicode.IsUpper(current):
+		bonus += bonusCamelCase
+	}
+	return bonus
+}
+
+func isSeparator(r rune) bool {
+	switch r {
+	case '/', '\\', '_', '-', '.', ' ':
+		return true
+	}
+	return false
+}
+
+// Index of the first rune of the last path element
+func baseNameStart(t []rune) int {
+	for i := len(t) - 1; i >= 0; i -= 1 {
+		if t[i] == '/' || t[i] == '\\' {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
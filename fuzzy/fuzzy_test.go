@@ -0,0 +1,62 @@
+package fuzzy
+
+import "testing"
+
+func TestScoreSubsequence(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		match   bool
+	}{
+		{"", "anything", true},
+		{"txb", "ui/textbox.go", true},
+		{"TXB", "ui/textbox.go", true},
+		{"bxt", "ui/textbox.go", false},
+		{"textboxes", "ui/textbox.go", false},
+		{"é", "café.go", true},
+	}
+	for _, test := range tests {
+		if _, ok := Score(test.pattern, test.target); ok != test.match {
+			t.Errorf("Score(%q, %q) match = %v, expected %v", test.pattern, test.target, ok, test.match)
+		}
+	}
+}
+
+func TestScoreOrdering(t *testing.T) {
+	better := func(pattern, a, b string) {
+		t.Helper()
+		sa, _ := Score(pattern, a)
+		sb, _ := Score(pattern, b)
+		if sa <= sb {
+			t.Errorf("%q should score better than %q for %q, got %d and %d", a, b, pattern, sa, sb)
+		}
+	}
+	// Consecutive runes
+	better("text", "ui/textbox.go", "ui/the_extra.go")
+	// Start of the words
+	better("tb", "ui/text_box.go", "ui/tabviewer.go")
+	better("tb", "ui/textBox.go", "ui/attribute.go")
+	// File name over the folders
+	better("edit", "ui/editor.go", "editor/utils.go")
+}
+
+func TestRank(t *testing.T) {
+	targets := []string{
+		"editor/texteditor.go",
+		"ui/textbox.go",
+		"ui/textbuffer.go",
+		"toml/token.go",
+		"ui/textbox_test.go",
+	}
+	matches := Rank("textbox", targets, 0)
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+	if targets[matches[0].Index] != "ui/textbox.go" {
+		t.Errorf("The shortest of the equal matches should come first, got %q", targets[matches[0].Index])
+	}
+
+	if matches := Rank("t", targets, 3); len(matches) != 3 {
+		t.Errorf("Expected the matches to be limited to 3, got %d", len(matches))
+	}
+}
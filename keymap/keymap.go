@@ -0,0 +1,317 @@
+// Package keymap maps the keys pressed to the names of
+// commands, depending on the context they are pressed in.
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nico-ec/uwu/toml"
+)
+
+type (
+	// A set of modifier keys held with a key
+	Modifiers uint8
+
+	// A key pressed with some modifiers, like ctrl+s
+	Chord struct {
+		Mods Modifiers
+		Key  string
+	}
+
+	// Bindings grouped by context. A binding is a sequence of
+	// chords, like "ctrl+k ctrl+t", and the command it runs
+	Keymap struct {
+		contexts map[string][]binding
+		// The chords of a sequence pressed so far
+		pending []Chord
+	}
+
+	binding struct {
+		keys    []Chord
+		command string
+	}
+)
+
+const (
+	Ctrl Modifiers = 1 << iota
+	Shift
+	Alt
+)
+
+// The names of the keys a chord can use
+var keyNames = func() map[string]bool {
+	names := map[string]bool{}
+	for c := 'a'; c <= 'z'; c += 1 {
+		names[string(c)] = true
+	}
+	for i := 0; i <= 9; i += 1 {
+		names[fmt.Sprint(i)] = true
+		names[fmt.Sprint("numpad", i)] = true
+	}
+	for i := 1; i <= 12; i += 1 {
+		names[fmt.Sprint("f", i)] = true
+	}
+	for _, name := range []string{
+		"escape", "enter", "tab", "space", "backspace", "delete", "insert",
+		"home", "end", "pageup", "pagedown", "up", "down", "left", "right",
+		"backquote", "backslash", "bracketleft", "bracketright", "comma",
+		"equal", "minus", "period", "quote", "semicolon", "slash",
+		"numpadadd", "numpaddecimal", "numpaddivide", "numpadenter",
+		"numpadequal", "numpadmultiply", "numpadsubtract",
+		"capslock", "contextmenu", "numlock", "pause", "printscreen", "scrolllock",
+	} {
+		names[name] = true
+	}
+	return names
+}()
+
+// Check if <name> is a key a chord can use
+func IsKey(name string) bool {
+	return keyNames[name]
+}
+
+// Read a chord written as modifiers and a key
+// joined by '+', like "ctrl+shift+p"
+func ParseChord(s string) (Chord, error) {
+	var c Chord
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for i, part := range parts {
+		if i == len(parts)-1 {
+			if !IsKey(part) {
+				return Chord{}, fmt.Errorf("unknown key %q in %q", part, s)
+			}
+			c.Key = part
+			break
+		}
+		var mod Modifiers
+		switch part {
+		case "ctrl":
+			mod = Ctrl
+		case "shift":
+			mod = Shift
+		case "alt":
+			mod = Alt
+		default:
+			return Chord{}, fmt.Errorf("unknown modifier %q in %q", part, s)
+		}
+		if c.Mods&mod != 0 {
+			return Chord{}, fmt.Errorf("modifier %q repeated in %q", part, s)
+		}
+		c.Mods |= mod
+	}
+	return c, nil
+}
+
+// Read a sequence of chords separated by spaces
+func ParseKeys(s string) ([]Chord, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no key given")
+	}
+	keys := make([]Chord, len(fields))
+	for i, field := range fields {
+		c, err := ParseChord(field)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = c
+	}
+	return keys, nil
+}
+
+func (c Chord) String() string {
+	var b strings.Builder
+	for _, mod := range []struct {
+		mod  Modifiers
+		name string
+	}{{Ctrl, "ctrl+"}, {Shift, "shift+"}, {Alt, "alt+"}} {
+		if c.Mods&mod.mod != 0 {
+			b.WriteString(mod.name)
+		}
+	}
+	b.WriteString(c.Key)
+	return b.String()
+}
+
+func New() *Keymap {
+	return &Keymap{
+		contexts: make(map[string][]binding),
+	}
+}
+
+// Make <keys> run <command> in <context>. A binding
+// already using the same keys in the context is replaced
+func (k *Keymap) Bind(context string, keys string, command string) error {
+	chords, err := ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	for i, b := range k.contexts[context] {
+		if equalKeys(b.keys, chords) {
+			k.contexts[context][i].command = command
+			return nil
+		}
+	}
+	k.contexts[context] = append(k.contexts[context], binding{
+		keys:    chords,
+		command: command,
+	})
+	return nil
+}
+
+// Bind the keys found in the TOML <source>. The tables are the
+// contexts, holding the keys of each command:
+//
+//	[editor]
+//	save = "ctrl+s"
+//	redo = ["ctrl+y", "ctrl+shift+z"]
+//
+// An invalid binding is skipped and reported
+func (k *Keymap) Load(source string) []error {
+	root, err := toml.Parse(source)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, context := range sortedKeys(root) {
+		table, ok := root[context].(toml.Table)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s should be a table of commands", context))
+			continue
+		}
+		for _, command := range sortedKeys(table) {
+			keys, ok := keysOf(table[command])
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s.%s should be a string or an array of strings", context, command))
+				continue
+			}
+			for _, s := range keys {
+				if err := k.Bind(context, s, command); err != nil {
+					errs = append(errs, fmt.Errorf("%s.%s: %w", context, command, err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func keysOf(v toml.Value) ([]string, bool) {
+	switch v := v.(type) {
+	case toml.String:
+		return []string{string(v)}, true
+	case *toml.Array:
+		keys := make([]string, v.Len())
+		for i := range keys {
+			s, ok := v.Get(i).(toml.String)
+			if !ok {
+				return nil, false
+			}
+			keys[i] = string(s)
+		}
+		return keys, true
+	}
+	return nil, false
+}
+
+// Feed a chord pressed while <contexts> are active, from the most specific
+// to the most general one. The first context binding the chords pressed so
+// far decides: the command is returned once its whole sequence is pressed
+func (k *Keymap) Press(c Chord, contexts ...string) (string, bool) {
+	seq := append(append([]Chord(nil), k.pending...), c)
+	for _, context := range contexts {
+		prefix := false
+		for _, b := range k.contexts[context] {
+			if equalKeys(b.keys, seq) {
+				k.pending = nil
+				return b.command, true
+			}
+			if len(b.keys) > len(seq) && equalKeys(b.keys[:len(seq)], seq) {
+				prefix = true
+			}
+		}
+		if prefix {
+			k.pending = seq
+			return "", false
+		}
+	}
+	// A sequence broken by an unbound chord starts over from it
+	if len(k.pending) > 0 {
+		k.pending = nil
+		return k.Press(c, contexts...)
+	}
+	return "", false
+}
+
+// The chords of the sequence being pressed
+func (k *Keymap) Pending() []Chord {
+	return k.pending
+}
+
+// The names of the commands bound in any context
+func (k *Keymap) Commands() []string {
+	seen := make(map[string]bool)
+	var commands []string
+	for _, bindings := range k.contexts {
+		for _, b := range bindings {
+			if !seen[b.command] {
+				seen[b.command] = true
+				commands = append(commands, b.command)
+			}
+		}
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// The keys bound to <command>, sorted by context
+func (k *Keymap) Bindings(command string) []string {
+	var keys []string
+	for _, context := range sortedContexts(k.contexts) {
+		for _, b := range k.contexts[context] {
+			if b.command == command {
+				keys = append(keys, keysString(b.keys))
+			}
+		}
+	}
+	return keys
+}
+
+func keysString(keys []Chord) string {
+	s := make([]string, len(keys))
+	for i, c := range keys {
+		s[i] = c.String()
+	}
+	return strings.Join(s, " ")
+}
+
+func sortedContexts(contexts map[string][]binding) []string {
+	names := make([]string, 0, len(contexts))
+	for name := range contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalKeys(a, b []Chord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(t toml.Table) []string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
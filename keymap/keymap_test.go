@@ -0,0 +1,117 @@
+package keymap
+
+import "testing"
+
+func chord(t *testing.T, s string) Chord {
+	t.Helper()
+	c, err := ParseChord(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestParseChord(t *testing.T) {
+	c := chord(t, "Ctrl+Shift+P")
+	if c.Mods != Ctrl|Shift || c.Key != "p" {
+		t.Errorf("Expected ctrl+shift+p, got %v", c)
+	}
+	if s := chord(t, "shift+alt+ctrl+f5").String(); s != "ctrl+shift+alt+f5" {
+		t.Errorf("Modifiers should be printed in order, got %q", s)
+	}
+
+	invalid := []string{"", "ctrl+", "ctrl", "super+a", "ctrl+ctrl+a", "a+b", "ctrl+unknown"}
+	for _, s := range invalid {
+		if _, err := ParseChord(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+	if _, err := ParseKeys("   "); err == nil {
+		t.Errorf("Expected an error for an empty sequence")
+	}
+}
+
+func TestContexts(t *testing.T) {
+	k := New()
+	k.Bind("global", "escape", "quit")
+	k.Bind("cmdpanel", "escape", "closepanel")
+	k.Bind("editor", "ctrl+s", "save")
+
+	if cmd, ok := k.Press(chord(t, "escape"), "cmdpanel", "global"); !ok || cmd != "closepanel" {
+		t.Errorf("The panel binding should win, got %q", cmd)
+	}
+	if cmd, ok := k.Press(chord(t, "escape"), "editor", "global"); !ok || cmd != "quit" {
+		t.Errorf("The global binding should be used, got %q", cmd)
+	}
+	if _, ok := k.Press(chord(t, "ctrl+s"), "cmdpanel", "global"); ok {
+		t.Errorf("ctrl+s isn't bound in the panel")
+	}
+	if _, ok := k.Press(chord(t, "s"), "editor", "global"); ok {
+		t.Errorf("The modifiers should match exactly")
+	}
+
+	k.Bind("editor", "ctrl+s", "saveall")
+	if cmd, _ := k.Press(chord(t, "ctrl+s"), "editor"); cmd != "saveall" {
+		t.Errorf("Binding the same keys should replace the command, got %q", cmd)
+	}
+}
+
+func TestSequences(t *testing.T) {
+	k := New()
+	k.Bind("editor", "ctrl+k ctrl+t", "theme")
+	k.Bind("editor", "ctrl+k ctrl+k ctrl+q", "quit")
+	k.Bind("editor", "ctrl+s", "save")
+
+	if _, ok := k.Press(chord(t, "ctrl+k"), "editor"); ok || len(k.Pending()) != 1 {
+		t.Fatalf("ctrl+k should start a sequence, pending %v", k.Pending())
+	}
+	if cmd, ok := k.Press(chord(t, "ctrl+t"), "editor"); !ok || cmd != "theme" {
+		t.Errorf("Expected the theme command, got %q", cmd)
+	}
+	if len(k.Pending()) != 0 {
+		t.Errorf("A finished sequence shouldn't be pending, got %v", k.Pending())
+	}
+
+	for _, s := range []string{"ctrl+k", "ctrl+k"} {
+		if _, ok := k.Press(chord(t, s), "editor"); ok {
+			t.Fatalf("%s shouldn't run a command", s)
+		}
+	}
+	if cmd, _ := k.Press(chord(t, "ctrl+q"), "editor"); cmd != "quit" {
+		t.Errorf("Expected the quit command, got %q", cmd)
+	}
+
+	// A broken sequence starts over with the last chord
+	k.Press(chord(t, "ctrl+k"), "editor")
+	if cmd, ok := k.Press(chord(t, "ctrl+s"), "editor"); !ok || cmd != "save" {
+		t.Errorf("Expected the save command after a broken sequence, got %q", cmd)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	k := New()
+	errs := k.Load(`
+	[editor]
+	save = "ctrl+s"
+	redo = ["ctrl+y", "ctrl+shift+z"]
+	broken = "ctrl+nope"
+	[treeview]
+	refresh = 5
+	`)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	for _, s := range []string{"ctrl+y", "ctrl+shift+z"} {
+		if cmd, _ := k.Press(chord(t, s), "editor"); cmd != "redo" {
+			t.Errorf("Expected %s to redo, got %q", s, cmd)
+		}
+	}
+	commands := k.Commands()
+	if len(commands) != 2 || commands[0] != "redo" || commands[1] != "save" {
+		t.Errorf("Expected the redo and save commands, got %v", commands)
+	}
+	k.Bind("cmdpanel", "ctrl+k shift+s", "save")
+	if keys := k.Bindings("save"); len(keys) != 2 || keys[0] != "ctrl+k shift+s" || keys[1] != "ctrl+s" {
+		t.Errorf("Expected the keys of save sorted by context, got %q", keys)
+	}
+}
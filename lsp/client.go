@@ -0,0 +1,355 @@
+// Package lsp is a client of the Language Server Protocol. It talks
+// JSON-RPC 2.0 with a language server over its stdin and stdout, to
+// keep it in sync with the edited files and ask for diagnostics,
+// hovers, completions and definitions.
+//
+// The requests block until the server answers, so the editor sends
+// them from another goroutine.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// A server not answering by then is considered stuck
+const requestTimeout = 10 * time.Second
+
+var ErrClosed = errors.New("language server closed")
+
+type Client struct {
+	conn   *conn
+	closer io.Closer
+	// nil if the server wasn't started by the client
+	cmd *exec.Cmd
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	// Version of each opened document, by URI
+	versions map[string]int
+	// Diagnostics received since the last TakeDiagnostics, by URI
+	diagnostics map[string][]Diagnostic
+
+	// Closed once the connection is lost, <err> telling why
+	done chan struct{}
+	err  error
+}
+
+// Run the language server <command> in <dir>, and connect
+// to it. The stderr of the server is discarded
+func Start(command []string, dir string) (*Client, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no language server command")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := NewClient(stdout, stdin)
+	c.cmd = cmd
+	return c, nil
+}
+
+// Connect to a server reading from <r> and writing
+// to <w>. <w> is closed with the client
+func NewClient(r io.Reader, w io.WriteCloser) *Client {
+	c := &Client{
+		conn:        newConn(r, w),
+		closer:      w,
+		pending:     make(map[int]chan *message),
+		versions:    make(map[string]int),
+		diagnostics: make(map[string][]Diagnostic),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *Client) readLoop() {
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			close(c.done)
+			return
+		}
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			// Writing could wait for the server, itself waiting
+			// for the messages it sends to be read
+			go c.answer(msg)
+		case msg.ID != nil:
+			var id int
+			if json.Unmarshal(*msg.ID, &id) != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params PublishDiagnosticsParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				c.mu.Lock()
+				c.diagnostics[params.URI] = params.Diagnostics
+				c.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Answer a request of the server. None of the client features
+// are supported, so the configuration items are left empty
+func (c *Client) answer(req *message) {
+	result := json.RawMessage("null")
+	if req.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(req.Params, &params)
+		nulls := make([]interface{}, len(params.Items))
+		result, _ = json.Marshal(nulls)
+	}
+	c.conn.write(&message{ID: req.ID, Result: result})
+}
+
+// Send a request and wait for its result, decoded into <result>
+func (c *Client) call(method string, params interface{}, result interface{}) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	data, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	c.nextID += 1
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(fmt.Sprint(id))
+	if err := c.conn.write(&message{ID: &rawID, Method: method, Params: data}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return fmt.Errorf("%s: %w", method, msg.Error)
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-c.done:
+		return ErrClosed
+	case <-time.After(requestTimeout):
+		return fmt.Errorf("%s: no answer from the language server", method)
+	}
+}
+
+func (c *Client) notify(method string, params interface{}) error {
+	data, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.conn.write(&message{Method: method, Params: data})
+}
+
+// The params are left out of the message when nil
+func marshalParams(params interface{}) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// Start the session, with <rootPath> as the workspace
+func (c *Client) Initialize(rootPath string) error {
+	params := map[string]interface{}{
+		"processId": nil,
+		"rootUri":   PathToURI(rootPath),
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{"didSave": true},
+				"publishDiagnostics": map[string]interface{}{},
+				"hover": map[string]interface{}{
+					"contentFormat": []string{"plaintext"},
+				},
+				"completion": map[string]interface{}{},
+				"definition": map[string]interface{}{},
+			},
+		},
+	}
+	if err := c.call("initialize", params, nil); err != nil {
+		return err
+	}
+	return c.notify("initialized", struct{}{})
+}
+
+// Tell the server the file at <path> is opened with the content <text>
+func (c *Client) DidOpen(path string, languageID string, text string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	c.versions[uri] = 1
+	c.mu.Unlock()
+	return c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": textDocumentItem{
+			URI:        uri,
+			LanguageID: languageID,
+			Version:    1,
+			Text:       text,
+		},
+	})
+}
+
+// Send the whole new content of the opened file at <path>
+func (c *Client) DidChange(path string, text string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	c.versions[uri] += 1
+	version := c.versions[uri]
+	c.mu.Unlock()
+	return c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   versionedTextDocumentIdentifier{URI: uri, Version: version},
+		"contentChanges": []textDocumentContentChangeEvent{{Text: text}},
+	})
+}
+
+func (c *Client) DidSave(path string, text string) error {
+	return c.notify("textDocument/didSave", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: PathToURI(path)},
+		"text":         text,
+	})
+}
+
+func (c *Client) DidClose(path string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	delete(c.versions, uri)
+	c.mu.Unlock()
+	return c.notify("textDocument/didClose", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: uri},
+	})
+}
+
+func positionParams(path string, pos Position) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: PathToURI(path)},
+		Position:     pos,
+	}
+}
+
+// The text describing the symbol at <pos>, "" if there is none
+func (c *Client) Hover(path string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.call("textDocument/hover", positionParams(path, pos), &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return hoverText(result.Contents), nil
+}
+
+// The completions at <pos>
+func (c *Client) Completion(path string, pos Position) ([]CompletionItem, error) {
+	var result json.RawMessage
+	if err := c.call("textDocument/completion", positionParams(path, pos), &result); err != nil {
+		return nil, err
+	}
+	// Either a CompletionList or an array of CompletionItem
+	var list struct {
+		Items []CompletionItem `json:"items"`
+	}
+	var items []CompletionItem
+	if json.Unmarshal(result, &items) == nil {
+		return items, nil
+	}
+	if err := json.Unmarshal(result, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Where the symbol at <pos> is defined
+func (c *Client) Definition(path string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.call("textDocument/definition", positionParams(path, pos), &result); err != nil {
+		return nil, err
+	}
+	return locations(result), nil
+}
+
+// The diagnostics published since the last call, by
+// URI. An empty list means a file has none left
+func (c *Client) TakeDiagnostics() map[string][]Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.diagnostics) == 0 {
+		return nil
+	}
+	taken := c.diagnostics
+	c.diagnostics = make(map[string][]Diagnostic)
+	return taken
+}
+
+// Closed once the connection with the server is lost
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// The reason the connection was lost
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// End the session and wait for the server to exit
+func (c *Client) Close() error {
+	err := c.call("shutdown", nil, nil)
+	if err == nil {
+		err = c.notify("exit", nil)
+	}
+	c.closer.Close()
+	if c.cmd != nil {
+		select {
+		case <-c.done:
+		case <-time.After(requestTimeout):
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	}
+	return err
+}
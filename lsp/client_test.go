@@ -0,0 +1,212 @@
+package lsp
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Run as the fake server when started by TestStart
+func TestMain(m *testing.M) {
+	if os.Getenv("UWU_FAKE_LSP") == "1" {
+		runFakeServer(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// A client connected to a fake server running in the test process
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go func() {
+		runFakeServer(serverR, serverW)
+		serverW.Close()
+	}()
+	c := NewClient(clientR, clientW)
+	if err := c.Initialize(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Wait for the diagnostics of <path> to be published
+func waitDiagnostics(t *testing.T, c *Client, path string) []Diagnostic {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if diags, ok := c.TakeDiagnostics()[PathToURI(path)]; ok {
+			return diags
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("No diagnostics published for %s", path)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	c := newTestClient(t)
+	defer c.Close()
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	if err := c.DidOpen(path, "go", "package main\n// TODO\n"); err != nil {
+		t.Fatal(err)
+	}
+	diags := waitDiagnostics(t, c, path)
+	expected := []Diagnostic{{
+		Range:    Range{Start: Position{1, 3}, End: Position{1, 7}},
+		Severity: SeverityWarning,
+		Message:  "unfinished",
+	}}
+	if !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Expected %v, got %v", expected, diags)
+	}
+
+	if err := c.DidChange(path, "package main\n"); err != nil {
+		t.Fatal(err)
+	}
+	if diags := waitDiagnostics(t, c, path); len(diags) != 0 {
+		t.Errorf("The diagnostics should be cleared after the change, got %v", diags)
+	}
+	if c.versions[PathToURI(path)] != 2 {
+		t.Errorf("Expected version 2 after a change, got %d", c.versions[PathToURI(path)])
+	}
+}
+
+func TestRequests(t *testing.T) {
+	c := newTestClient(t)
+	defer c.Close()
+	path := filepath.Join(t.TempDir(), "main.go")
+	c.DidOpen(path, "go", "package main")
+
+	hover, err := c.Hover(path, Position{Line: 0, Character: 3})
+	if err != nil || hover != "hover at 0:3" {
+		t.Errorf("Hover returned %q, %v", hover, err)
+	}
+
+	items, err := c.Completion(path, Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, item := range items {
+		labels = append(labels, item.Text())
+	}
+	if !reflect.DeepEqual(labels, []string{"package", "main"}) {
+		t.Errorf("Completion returned %v", labels)
+	}
+
+	locs, err := c.Definition(path, Position{})
+	if err != nil || len(locs) != 1 || URIToPath(locs[0].URI) != path {
+		t.Errorf("Definition returned %v, %v", locs, err)
+	}
+}
+
+// The server runs in another process, talking over its stdio
+func TestStart(t *testing.T) {
+	os.Setenv("UWU_FAKE_LSP", "1")
+	defer os.Unsetenv("UWU_FAKE_LSP")
+	c, err := Start([]string{os.Args[0]}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Initialize(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if hover, err := c.Hover("main.go", Position{Line: 2, Character: 1}); err != nil || hover != "hover at 2:1" {
+		t.Errorf("Hover returned %q, %v", hover, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Errorf("The connection should be closed once the server exited")
+	}
+	if _, err := c.Hover("main.go", Position{}); err != ErrClosed {
+		t.Errorf("A request after Close should fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestHoverContents(t *testing.T) {
+	tests := []struct {
+		contents string
+		text     string
+	}{
+		{`"plain"`, "plain"},
+		{`{"kind": "markdown", "value": "*doc*"}`, "*doc*"},
+		{`{"language": "go", "value": "func f()"}`, "func f()"},
+		{`["a", {"language": "go", "value": "b"}]`, "a\n\nb"},
+	}
+	for _, test := range tests {
+		if text := hoverText([]byte(test.contents)); text != test.text {
+			t.Errorf("hoverText(%s) = %q, expected %q", test.contents, text, test.text)
+		}
+	}
+}
+
+func TestLocations(t *testing.T) {
+	single := `{"uri": "file:///a.go", "range": {"start": {"line": 1, "character": 2}, "end": {"line": 1, "character": 3}}}`
+	link := `[{"targetUri": "file:///b.go", "targetRange": {}, "targetSelectionRange": {"start": {"line": 4, "character": 0}, "end": {"line": 4, "character": 1}}}]`
+	if locs := locations([]byte(single)); len(locs) != 1 || locs[0].URI != "file:///a.go" || locs[0].Range.Start != (Position{1, 2}) {
+		t.Errorf("Expected a.go at 1:2, got %v", locs)
+	}
+	if locs := locations([]byte(link)); len(locs) != 1 || locs[0].URI != "file:///b.go" || locs[0].Range.Start.Line != 4 {
+		t.Errorf("Expected b.go at line 4, got %v", locs)
+	}
+	if locs := locations([]byte("null")); len(locs) != 0 {
+		t.Errorf("Expected no location, got %v", locs)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	// 'é' is a single UTF-16 unit, '😀' two
+	line := []rune("é😀x")
+	for col, expected := range []int{0, 1, 3, 4} {
+		if c := UTF16Column(line, col); c != expected {
+			t.Errorf("UTF16Column(%d) = %d, expected %d", col, c, expected)
+		}
+		if c := RuneColumn(line, expected); c != col {
+			t.Errorf("RuneColumn(%d) = %d, expected %d", expected, c, col)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "my file.go")
+	uri := PathToURI(path)
+	if back := URIToPath(uri); back != path {
+		t.Errorf("%s read back as %s from %s", path, back, uri)
+	}
+	if URIToPath("https://example.com/a.go") != "" {
+		t.Errorf("Only file URIs have a path")
+	}
+}
+
+func TestWindowsURI(t *testing.T) {
+	for _, uri := range []string{
+		"file:///C:/dir/a.go",
+		"file:///c:/dir/a.go",
+		"file:///c%3A/dir/a.go",
+	} {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := uriPath(u.Path, true); p != "C:/dir/a.go" {
+			t.Errorf("%s read as %s, expected C:/dir/a.go", uri, p)
+		}
+	}
+	if pathKey(`c:\Dir\.\a.go`, true) != pathKey("C:/dir/a.go", true) {
+		t.Errorf("The paths should be the same file on Windows")
+	}
+	if pathKey("/Dir/a.go", false) == pathKey("/dir/a.go", false) {
+		t.Errorf("The paths are different files elsewhere")
+	}
+}
@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A small language server for the tests. It keeps the opened
+// documents and reports a diagnostic on each line holding "TODO".
+// The hover describes the position, the completions are the words
+// of the document, and every symbol is defined at the start of it
+type fakeServer struct {
+	conn *conn
+	docs map[string]string
+	// The method of each message received, in order
+	received []string
+}
+
+func runFakeServer(r io.Reader, w io.Writer) {
+	s := &fakeServer{
+		conn: newConn(r, w),
+		docs: make(map[string]string),
+	}
+	s.run()
+}
+
+func (s *fakeServer) run() {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			return
+		}
+		if msg.Method == "" {
+			// The answer to workspace/configuration
+			continue
+		}
+		s.received = append(s.received, msg.Method)
+
+		var result interface{}
+		switch msg.Method {
+		case "initialize":
+			result = map[string]interface{}{
+				"capabilities": map[string]interface{}{"textDocumentSync": 1},
+			}
+		case "initialized":
+			// Servers ask for their settings once initialized
+			id := json.RawMessage(`"config"`)
+			params, _ := json.Marshal(map[string]interface{}{
+				"items": []interface{}{map[string]string{"section": "fake"}},
+			})
+			s.conn.write(&message{ID: &id, Method: "workspace/configuration", Params: params})
+		case "textDocument/didOpen":
+			var params struct {
+				TextDocument textDocumentItem `json:"textDocument"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		case "textDocument/didChange":
+			var params struct {
+				TextDocument   versionedTextDocumentIdentifier  `json:"textDocument"`
+				ContentChanges []textDocumentContentChangeEvent `json:"contentChanges"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			for _, change := range params.ContentChanges {
+				s.update(params.TextDocument.URI, change.Text)
+			}
+		case "textDocument/hover":
+			var params textDocumentPositionParams
+			json.Unmarshal(msg.Params, &params)
+			result = map[string]interface{}{
+				"contents": map[string]string{
+					"kind":  "plaintext",
+					"value": fmt.Sprintf("hover at %d:%d", params.Position.Line, params.Position.Character),
+				},
+			}
+		case "textDocument/completion":
+			var params textDocumentPositionParams
+			json.Unmarshal(msg.Params, &params)
+			var items []CompletionItem
+			for _, word := range strings.Fields(s.docs[params.TextDocument.URI]) {
+				items = append(items, CompletionItem{Label: word})
+			}
+			result = map[string]interface{}{"isIncomplete": false, "items": items}
+		case "textDocument/definition":
+			var params textDocumentPositionParams
+			json.Unmarshal(msg.Params, &params)
+			result = []Location{{URI: params.TextDocument.URI}}
+		case "shutdown":
+			result = nil
+		case "exit":
+			return
+		}
+
+		if msg.ID != nil {
+			data, _ := json.Marshal(result)
+			s.conn.write(&message{ID: msg.ID, Result: data})
+		}
+	}
+}
+
+func (s *fakeServer) update(uri string, text string) {
+	s.docs[uri] = text
+	diagnostics := []Diagnostic{}
+	for i, line := range strings.Split(text, "\n") {
+		if col := strings.Index(line, "TODO"); col >= 0 {
+			diagnostics = append(diagnostics, Diagnostic{
+				Range: Range{
+					Start: Position{Line: i, Character: col},
+					End:   Position{Line: i, Character: col + 4},
+				},
+				Severity: SeverityWarning,
+				Message:  "unfinished",
+			})
+		}
+	}
+	params, _ := json.Marshal(PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	s.conn.write(&message{Method: "textDocument/publishDiagnostics", Params: params})
+}
@@ -0,0 +1,239 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// The subset of the protocol the editor uses
+type (
+	// Line and Character start at 0. Character counts UTF-16
+	// code units, see UTF16Column and RuneColumn
+	Position struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+
+	Range struct {
+		Start Position `json:"start"`
+		End   Position `json:"end"`
+	}
+
+	Location struct {
+		URI   string `json:"uri"`
+		Range Range  `json:"range"`
+	}
+
+	DiagnosticSeverity int
+
+	Diagnostic struct {
+		Range    Range              `json:"range"`
+		Severity DiagnosticSeverity `json:"severity,omitempty"`
+		Source   string             `json:"source,omitempty"`
+		Message  string             `json:"message"`
+	}
+
+	PublishDiagnosticsParams struct {
+		URI         string       `json:"uri"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}
+
+	TextEdit struct {
+		Range   Range  `json:"range"`
+		NewText string `json:"newText"`
+	}
+
+	CompletionItem struct {
+		Label      string    `json:"label"`
+		Kind       int       `json:"kind,omitempty"`
+		Detail     string    `json:"detail,omitempty"`
+		InsertText string    `json:"insertText,omitempty"`
+		TextEdit   *TextEdit `json:"textEdit,omitempty"`
+	}
+
+	textDocumentItem struct {
+		URI        string `json:"uri"`
+		LanguageID string `json:"languageId"`
+		Version    int    `json:"version"`
+		Text       string `json:"text"`
+	}
+
+	textDocumentIdentifier struct {
+		URI string `json:"uri"`
+	}
+
+	versionedTextDocumentIdentifier struct {
+		URI     string `json:"uri"`
+		Version int    `json:"version"`
+	}
+
+	// A change without range replaces the whole text
+	textDocumentContentChangeEvent struct {
+		Text string `json:"text"`
+	}
+
+	textDocumentPositionParams struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+		Position     Position               `json:"position"`
+	}
+)
+
+const (
+	SeverityError DiagnosticSeverity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	}
+	// A missing severity is left to the client, as an error
+	return "error"
+}
+
+// The text inserted by the item
+func (item CompletionItem) Text() string {
+	switch {
+	case item.TextEdit != nil:
+		return item.TextEdit.NewText
+	case item.InsertText != "":
+		return item.InsertText
+	}
+	return item.Label
+}
+
+// The file URI of <path>
+func PathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	// C:/dir is written file:///C:/dir
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// The path of a file URI, "" if <uri> isn't one
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(uriPath(u.Path, runtime.GOOS == "windows"))
+}
+
+// The path of an unescaped URI path. On <windows> it starts with the
+// drive letter, in uppercase whatever the server wrote
+func uriPath(p string, windows bool) string {
+	if !windows {
+		return p
+	}
+	p = strings.TrimPrefix(p, "/")
+	if len(p) >= 2 && p[1] == ':' {
+		p = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return p
+}
+
+// A form of <path> to compare it with another one, since the
+// servers don't always write a path like the editor: cleaned,
+// and case-insensitive on Windows as its file names are
+func PathKey(path string) string {
+	return pathKey(path, runtime.GOOS == "windows")
+}
+
+func pathKey(p string, windows bool) string {
+	if windows {
+		p = strings.ToLower(strings.ReplaceAll(p, `\`, "/"))
+	}
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// The UTF-16 column of the rune column <col> of <line>
+func UTF16Column(line []rune, col int) int {
+	if col > len(line) {
+		col = len(line)
+	}
+	n := 0
+	for _, r := range line[:col] {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// The rune column of the UTF-16 column <col> of <line>
+func RuneColumn(line []rune, col int) int {
+	n := 0
+	for i, r := range line {
+		if n >= col {
+			return i
+		}
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// Read the contents of a hover, which can be a MarkupContent,
+// a MarkedString or an array of MarkedString
+func hoverText(contents json.RawMessage) string {
+	var markup struct {
+		Value string `json:"value"`
+	}
+	var text string
+	var list []json.RawMessage
+	switch {
+	case json.Unmarshal(contents, &text) == nil:
+		return text
+	case json.Unmarshal(contents, &list) == nil:
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if s := hoverText(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	case json.Unmarshal(contents, &markup) == nil:
+		return markup.Value
+	}
+	return ""
+}
+
+// Read a definition, which can be a Location,
+// an array of Location or an array of LocationLink
+func locations(result json.RawMessage) []Location {
+	var list []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	if json.Unmarshal(result, &list) != nil {
+		var single Location
+		if json.Unmarshal(result, &single) != nil || single.URI == "" {
+			return nil
+		}
+		return []Location{single}
+	}
+	locs := make([]Location, 0, len(list))
+	for _, l := range list {
+		if l.TargetURI != "" {
+			locs = append(locs, Location{URI: l.TargetURI, Range: l.TargetSelectionRange})
+		} else {
+			locs = append(locs, l.Location)
+		}
+	}
+	return locs
+}
@@ -10,10 +10,9 @@ import (
 
 func main() {
 	// defer profile.Start(profile.CPUProfile, profile.ProfilePath(".")).Stop()
-	ebiten.SetWindowSize(1600, 900)
+	// The window size and the TPS come from the editor settings
 	ebiten.SetWindowDecorated(false)
 	ebiten.SetRunnableOnUnfocused(false)
-	ebiten.SetMaxTPS(30)
 
 	ed := editor.NewEditor()
 
@@ -0,0 +1,175 @@
+// Package outline lists the top level declarations of a source
+// file, to navigate between them.
+package outline
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"unicode/utf8"
+)
+
+type (
+	Kind uint8
+
+	Symbol struct {
+		// Methods are named after their receiver, like "TextBox.Undo"
+		Name string
+		Kind Kind
+		// Line starts at 1, Column counts the runes before the name
+		Line   int
+		Column int
+	}
+)
+
+const (
+	Func Kind = iota
+	Method
+	Type
+	Const
+	Var
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Method:
+		return "method"
+	case Type:
+		return "type"
+	case Const:
+		return "const"
+	case Var:
+		return "var"
+	}
+	return "func"
+}
+
+// List the funcs, methods, types, consts and vars declared
+// at the top level of the Go source <src>, in their order.
+// On a syntax error, the declarations are read one by one
+// so only the broken one is lost
+func ParseGo(src []byte) []Symbol {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err == nil {
+		return collect(fset, file, src, 0)
+	}
+	if file == nil || file.Name == nil {
+		return nil
+	}
+
+	// The parser gives up at the first unbalanced brace or paren,
+	// but a top level declaration always starts a line
+	var symbols []Symbol
+	starts := declStarts(src)
+	for i, start := range starts {
+		end := len(src)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		chunk := append([]byte(chunkHeader), src[start:end]...)
+		file, _ := parser.ParseFile(fset, "", chunk, parser.SkipObjectResolution)
+		if file != nil {
+			symbols = append(symbols, collect(fset, file, src, start-len(chunkHeader))...)
+		}
+	}
+	return symbols
+}
+
+// Put before each declaration read on its own
+const chunkHeader = "package p\n"
+
+// The offsets of the lines starting a top level declaration
+func declStarts(src []byte) []int {
+	var starts []int
+	for offset := 0; offset < len(src); {
+		line := src[offset:]
+		for _, keyword := range []string{"func", "type", "var", "const"} {
+			rest := bytes.TrimPrefix(line, []byte(keyword))
+			if len(rest) < len(line) && len(rest) > 0 && (rest[0] == ' ' || rest[0] == '(' || rest[0] == '\t') {
+				starts = append(starts, offset)
+				break
+			}
+		}
+		next := bytes.IndexByte(line, '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+	}
+	return starts
+}
+
+// The symbols of <file>, whose offset <base> is the start of <src>
+func collect(fset *token.FileSet, file *ast.File, src []byte, base int) []Symbol {
+	var symbols []Symbol
+	add := func(ident *ast.Ident, name string, kind Kind) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		offset := base + fset.Position(ident.Pos()).Offset
+		line, col := position(src, offset)
+		symbols = append(symbols, Symbol{
+			Name:   name,
+			Kind:   kind,
+			Line:   line,
+			Column: col,
+		})
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv := receiverName(decl.Recv.List[0].Type)
+				add(decl.Name, recv+"."+decl.Name.Name, Method)
+			} else {
+				add(decl.Name, decl.Name.Name, Func)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					add(spec.Name, spec.Name.Name, Type)
+				case *ast.ValueSpec:
+					kind := Var
+					if decl.Tok == token.CONST {
+						kind = Const
+					}
+					for _, name := range spec.Names {
+						add(name, name.Name, kind)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// The name of the type of a receiver, without pointer or type parameters
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return "?"
+		}
+	}
+}
+
+// The line (starting at 1) and the rune column of a byte offset
+func position(src []byte, offset int) (int, int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	line := bytes.Count(src[:lineStart], []byte{'\n'}) + 1
+	return line, utf8.RuneCount(src[lineStart:offset])
+}
@@ -0,0 +1,74 @@
+package outline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGo(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+const (
+	a, b = 1, 2
+	_    = 3
+)
+
+var é, count int
+
+type (
+	Point struct{ X, Y int }
+	List[T any] []T
+)
+
+func main() {
+	fmt.Println(a)
+}
+
+func (p *Point) Move(dx int) {}
+
+func (l List[T]) Len() int { return len(l) }
+`
+	expected := []Symbol{
+		{"a", Const, 6, 1},
+		{"b", Const, 6, 4},
+		{"é", Var, 10, 4},
+		{"count", Var, 10, 7},
+		{"Point", Type, 13, 1},
+		{"List", Type, 14, 1},
+		{"main", Func, 17, 5},
+		{"Point.Move", Method, 21, 16},
+		{"List.Len", Method, 23, 17},
+	}
+	if symbols := ParseGo([]byte(src)); !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("Expected\n%v\ngot\n%v", expected, symbols)
+	}
+}
+
+func TestParseGoSyntaxError(t *testing.T) {
+	src := `package main
+
+func broken() {
+	x :=
+	fmt.Println(
+}
+
+func after() {}
+
+type T int
+`
+	names := map[string]bool{}
+	for _, s := range ParseGo([]byte(src)) {
+		names[s.Name] = true
+	}
+	for _, name := range []string{"broken", "after", "T"} {
+		if !names[name] {
+			t.Errorf("%s should be found after the syntax error, got %v", name, names)
+		}
+	}
+
+	if symbols := ParseGo([]byte("not go at all")); len(symbols) != 0 {
+		t.Errorf("Expected no symbol without a package clause, got %v", symbols)
+	}
+}
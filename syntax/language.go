@@ -0,0 +1,70 @@
+// Package syntax provides the per-language lexers used to
+// highlight the content of a ui.TextBox.
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Describe the lexical rules of a language
+	Language struct {
+		Name       string
+		Extensions []string
+		Keywords   []string
+		// Builtin types, colored apart from the keywords
+		Types []string
+
+		LineComment       string
+		BlockCommentStart string
+		BlockCommentEnd   string
+
+		// Runes opening and closing a string literal
+		StringDelimiters []rune
+		EscapeChar       rune
+		// Strings without escapes that can span several lines
+		RawStringDelimiters []rune
+
+		Numbers NumberRules
+		// Pairs of opening and closing brackets, like "()"
+		Brackets [][2]rune
+	}
+
+	NumberRules struct {
+		// Prefixes of the hexadecimal, binary and octal
+		// literals, followed by any hexadecimal digit
+		Prefixes []string
+		// Rune allowed between the digits, 0 if there is none
+		Separator rune
+		// Runes starting the exponent of a float
+		Exponent string
+		// Runes allowed right after a number, like the 'f' in 1.0f
+		Suffixes string
+	}
+)
+
+var languages = make(map[string]*Lexer)
+
+// Make a language available to ForFile. A language registered
+// for an extension replaces the previous one
+func Register(lang Language) {
+	lexer := NewLexer(lang)
+	for _, ext := range lang.Extensions {
+		languages[normalizeExtension(ext)] = lexer
+	}
+}
+
+// The lexer of the language used by <path>,
+// based on its extension. nil if there is none
+func ForFile(path string) *Lexer {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return nil
+	}
+	return languages[normalizeExtension(ext)]
+}
+
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
@@ -0,0 +1,305 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/nico-ec/uwu/ui"
+)
+
+// The states a line can end with
+const (
+	stateNormal ui.LineState = iota
+	stateBlockComment
+	// Inside a raw string. The index of its delimiter
+	// in Language.RawStringDelimiters is added to it
+	stateRawString
+)
+
+// A ui.Highlighter built from the rules of a Language
+type Lexer struct {
+	lang     Language
+	keywords map[string]bool
+	types    map[string]bool
+
+	// State of the line being lexed
+	input   []rune
+	current int
+}
+
+func NewLexer(lang Language) *Lexer {
+	l := &Lexer{
+		lang:     lang,
+		keywords: make(map[string]bool, len(lang.Keywords)),
+		types:    make(map[string]bool, len(lang.Types)),
+	}
+	for _, kw := range lang.Keywords {
+		l.keywords[kw] = true
+	}
+	for _, t := range lang.Types {
+		l.types[t] = true
+	}
+	return l
+}
+
+func (l *Lexer) Language() *Language {
+	return &l.lang
+}
+
+// The keywords and builtin types of the language, so
+// the completion of a ui.TextBox can offer them
+func (l *Lexer) Keywords() []string {
+	words := make([]string, 0, len(l.lang.Keywords)+len(l.lang.Types))
+	words = append(words, l.lang.Keywords...)
+	return append(words, l.lang.Types...)
+}
+
+func (l *Lexer) HighlightLine(line []rune, state ui.LineState, tokens []ui.Token) ([]ui.Token, ui.LineState) {
+	l.input = line
+	l.current = 0
+
+	// Finish what the previous line left open
+	var closed bool
+	var kind ui.TokenKind
+	switch {
+	case state == stateBlockComment:
+		closed = l.skipPast(l.lang.BlockCommentEnd)
+		kind = ui.TokenComment
+	case state >= stateRawString && int(state-stateRawString) < len(l.lang.RawStringDelimiters):
+		closed = l.skipPast(string(l.lang.RawStringDelimiters[state-stateRawString]))
+		kind = ui.TokenString
+	default:
+		closed = true
+	}
+	if l.current > 0 {
+		tokens = append(tokens, ui.Token{Start: 0, End: l.current, Kind: kind})
+	}
+	if !closed {
+		return tokens, state
+	}
+	state = stateNormal
+
+	for !l.eof() {
+		start := l.current
+		c := l.peek()
+
+		switch {
+		case unicode.IsSpace(c):
+			l.advance()
+			continue
+
+		case l.match(l.lang.LineComment):
+			l.current = len(l.input)
+			kind = ui.TokenComment
+
+		case l.match(l.lang.BlockCommentStart):
+			l.current += len([]rune(l.lang.BlockCommentStart))
+			if !l.skipPast(l.lang.BlockCommentEnd) {
+				state = stateBlockComment
+			}
+			kind = ui.TokenComment
+
+		case l.rawStringIndex(c) >= 0:
+			l.advance()
+			if !l.skipPast(string(c)) {
+				state = stateRawString + ui.LineState(l.rawStringIndex(c))
+			}
+			kind = ui.TokenString
+
+		case l.isStringDelimiter(c):
+			l.advance()
+			l.lexString(c)
+			kind = ui.TokenString
+
+		case isDigit(c):
+			l.lexNumber()
+			kind = ui.TokenNumber
+
+		case isIdentStart(c):
+			kind = l.lexIdentifier()
+
+		default:
+			l.advance()
+			kind = ui.TokenSymbol
+		}
+		tokens = append(tokens, ui.Token{
+			Start: start,
+			End:   l.current,
+			Kind:  kind,
+		})
+	}
+	return tokens, state
+}
+
+func (l *Lexer) lexString(delimiter rune) {
+	for !l.eof() {
+		c := l.advance()
+		switch {
+		case c == l.lang.EscapeChar && l.lang.EscapeChar != 0:
+			if !l.eof() {
+				l.advance()
+			}
+		case c == delimiter:
+			return
+		}
+	}
+}
+
+func (l *Lexer) lexNumber() {
+	rules := &l.lang.Numbers
+	for _, prefix := range rules.Prefixes {
+		if l.match(prefix) {
+			l.current += len([]rune(prefix))
+			l.skipDigits(isHexDigit)
+			l.skipSuffix()
+			return
+		}
+	}
+
+	l.skipDigits(isDigit)
+	if l.peekAt(0) == '.' && isDigit(l.peekAt(1)) {
+		l.advance()
+		l.skipDigits(isDigit)
+	}
+	if e := l.peekAt(0); e != 0 && strings.ContainsRune(rules.Exponent, e) {
+		sign := l.peekAt(1)
+		switch {
+		case isDigit(sign):
+			l.advance()
+		case (sign == '+' || sign == '-') && isDigit(l.peekAt(2)):
+			l.advance()
+			l.advance()
+		}
+		l.skipDigits(isDigit)
+	}
+	l.skipSuffix()
+}
+
+func (l *Lexer) skipDigits(isDigit func(r rune) bool) {
+	sep := l.lang.Numbers.Separator
+	for !l.eof() {
+		c := l.peek()
+		if !isDigit(c) && (sep == 0 || c != sep) {
+			break
+		}
+		l.advance()
+	}
+}
+
+func (l *Lexer) skipSuffix() {
+	for !l.eof() && strings.ContainsRune(l.lang.Numbers.Suffixes, l.peek()) {
+		l.advance()
+	}
+}
+
+func (l *Lexer) lexIdentifier() ui.TokenKind {
+	start := l.current
+	for !l.eof() && isIdentPart(l.peek()) {
+		l.advance()
+	}
+	word := string(l.input[start:l.current])
+	switch {
+	case l.keywords[word]:
+		return ui.TokenKeyword
+	case l.types[word]:
+		return ui.TokenType
+	case l.nextNonSpace() == '(':
+		return ui.TokenFunction
+	}
+	return ui.TokenNormal
+}
+
+// Move past the next occurrence of <end>, or to the end of
+// the line. Return false if <end> wasn't found
+func (l *Lexer) skipPast(end string) bool {
+	if end == "" {
+		l.current = len(l.input)
+		return true
+	}
+	rest := string(l.input[l.current:])
+	if i := strings.Index(rest, end); i >= 0 {
+		l.current += len([]rune(rest[:i+len(end)]))
+		return true
+	}
+	l.current = len(l.input)
+	return false
+}
+
+// Check if the input continues with <prefix>
+func (l *Lexer) match(prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	i := l.current
+	for _, r := range prefix {
+		if i >= len(l.input) || l.input[i] != r {
+			return false
+		}
+		i += 1
+	}
+	return true
+}
+
+func (l *Lexer) isStringDelimiter(c rune) bool {
+	for _, d := range l.lang.StringDelimiters {
+		if c == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Lexer) rawStringIndex(c rune) int {
+	for i, d := range l.lang.RawStringDelimiters {
+		if c == d {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *Lexer) nextNonSpace() rune {
+	for i := l.current; i < len(l.input); i += 1 {
+		if !unicode.IsSpace(l.input[i]) {
+			return l.input[i]
+		}
+	}
+	return 0
+}
+
+func (l *Lexer) advance() rune {
+	l.current += 1
+	return l.input[l.current-1]
+}
+
+func (l *Lexer) peek() rune {
+	return l.input[l.current]
+}
+
+// The rune <n> runes after the current one, 0 past the end
+func (l *Lexer) peekAt(n int) rune {
+	if l.current+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.current+n]
+}
+
+func (l *Lexer) eof() bool {
+	return l.current >= len(l.input)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
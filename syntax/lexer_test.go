@@ -0,0 +1,180 @@
+package syntax
+
+import (
+	"testing"
+
+	"github.com/nico-ec/uwu/ui"
+)
+
+type expectedToken struct {
+	text string
+	kind ui.TokenKind
+}
+
+func checkTokens(t *testing.T, l *Lexer, line string, expected []expectedToken) {
+	t.Helper()
+	runes := []rune(line)
+	tokens, state := l.HighlightLine(runes, stateNormal, nil)
+	if state != stateNormal {
+		t.Fatalf("%q should end in the normal state, got %d", line, state)
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens for %q, got %d: %v", len(expected), line, len(tokens), tokens)
+	}
+	for i, tok := range tokens {
+		text := string(runes[tok.Start:tok.End])
+		if text != expected[i].text || tok.Kind != expected[i].kind {
+			t.Errorf("Token %d of %q: expected %q (%d), got %q (%d)",
+				i, line, expected[i].text, expected[i].kind, text, tok.Kind)
+		}
+	}
+}
+
+// Register the languages shipped with the editor
+func loadLanguages(t *testing.T) {
+	t.Helper()
+	for _, err := range LoadDir("../languages") {
+		t.Error(err)
+	}
+}
+
+func TestGoLexer(t *testing.T) {
+	loadLanguages(t)
+	l := ForFile("main.go")
+	if l == nil {
+		t.Fatal("No lexer registered for .go files")
+	}
+
+	checkTokens(t, l, `func main() {`, []expectedToken{
+		{"func", ui.TokenKeyword},
+		{"main", ui.TokenFunction},
+		{"(", ui.TokenSymbol},
+		{")", ui.TokenSymbol},
+		{"{", ui.TokenSymbol},
+	})
+	checkTokens(t, l, `var s string = "a \"b\"" // done`, []expectedToken{
+		{"var", ui.TokenKeyword},
+		{"s", ui.TokenNormal},
+		{"string", ui.TokenType},
+		{"=", ui.TokenSymbol},
+		{`"a \"b\""`, ui.TokenString},
+		{"// done", ui.TokenComment},
+	})
+	checkTokens(t, l, `x := 0x1F + 2.5e-3 /* c */ + 'é' + 1_000i`, []expectedToken{
+		{"x", ui.TokenNormal},
+		{":", ui.TokenSymbol},
+		{"=", ui.TokenSymbol},
+		{"0x1F", ui.TokenNumber},
+		{"+", ui.TokenSymbol},
+		{"2.5e-3", ui.TokenNumber},
+		{"/* c */", ui.TokenComment},
+		{"+", ui.TokenSymbol},
+		{"'é'", ui.TokenString},
+		{"+", ui.TokenSymbol},
+		{"1_000i", ui.TokenNumber},
+	})
+	checkTokens(t, l, `1.x 2else`, []expectedToken{
+		{"1", ui.TokenNumber},
+		{".", ui.TokenSymbol},
+		{"x", ui.TokenNormal},
+		{"2", ui.TokenNumber},
+		{"else", ui.TokenKeyword},
+	})
+}
+
+func TestForFile(t *testing.T) {
+	if ForFile("README") != nil || ForFile("notes.unknown") != nil {
+		t.Errorf("Unknown extensions should have no lexer")
+	}
+	Register(Language{
+		Name:        "Test",
+		Extensions:  []string{".TST"},
+		LineComment: "#",
+	})
+	l := ForFile("dir/file.tst")
+	if l == nil || l.Language().Name != "Test" {
+		t.Fatalf("Expected the registered language, got %v", l)
+	}
+	checkTokens(t, l, "a # b", []expectedToken{
+		{"a", ui.TokenNormal},
+		{"# b", ui.TokenComment},
+	})
+}
+
+func TestMultilineState(t *testing.T) {
+	loadLanguages(t)
+	l := ForFile("main.go")
+	lines := []struct {
+		text  string
+		state ui.LineState
+		kinds []ui.TokenKind
+	}{
+		{"x /* start", stateBlockComment, []ui.TokenKind{ui.TokenNormal, ui.TokenComment}},
+		{"", stateBlockComment, nil},
+		{"still */ y", stateNormal, []ui.TokenKind{ui.TokenComment, ui.TokenNormal}},
+		{"s := `raw \\", stateRawString, []ui.TokenKind{ui.TokenNormal, ui.TokenSymbol, ui.TokenSymbol, ui.TokenString}},
+		{"/* not a comment", stateRawString, []ui.TokenKind{ui.TokenString}},
+		{"end` // c", stateNormal, []ui.TokenKind{ui.TokenString, ui.TokenComment}},
+	}
+	state := stateNormal
+	for _, line := range lines {
+		var tokens []ui.Token
+		tokens, state = l.HighlightLine([]rune(line.text), state, nil)
+		if state != line.state {
+			t.Fatalf("%q should end in state %d, got %d", line.text, line.state, state)
+		}
+		if len(tokens) != len(line.kinds) {
+			t.Fatalf("Expected %d tokens for %q, got %v", len(line.kinds), line.text, tokens)
+		}
+		for i, tok := range tokens {
+			if tok.Kind != line.kinds[i] {
+				t.Errorf("Token %d of %q: expected kind %d, got %d", i, line.text, line.kinds[i], tok.Kind)
+			}
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	lang, err := ParseLanguage(`
+	name = "Test"
+	extensions = ["tst"]
+	brackets = ["()", "<>"]
+	[comments]
+	block = ["{-", "-}"]
+	[strings]
+	delimiters = ['"']
+	escape = '\'
+	[numbers]
+	separator = "'"
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang.BlockCommentStart != "{-" || lang.BlockCommentEnd != "-}" {
+		t.Errorf("Wrong block comment delimiters, got %q and %q", lang.BlockCommentStart, lang.BlockCommentEnd)
+	}
+	if len(lang.Brackets) != 2 || lang.Brackets[1] != [2]rune{'<', '>'} {
+		t.Errorf("Wrong brackets, got %q", lang.Brackets)
+	}
+	if lang.EscapeChar != '\\' || lang.Numbers.Separator != '\'' {
+		t.Errorf("Wrong escape or separator, got %q and %q", lang.EscapeChar, lang.Numbers.Separator)
+	}
+
+	invalid := []string{
+		`extensions = ["x"]`,
+		`name = "x"
+		extensions = "x"`,
+		`name = "x"
+		extensions = ["x"]
+		brackets = ["("]`,
+		`name = "x"
+		extensions = ["x"]
+		[strings]
+		escape = "ab"`,
+	}
+	for _, source := range invalid {
+		if _, err := ParseLanguage(source); err == nil {
+			t.Errorf("Expected an error for %q", source)
+		}
+	}
+}
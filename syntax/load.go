@@ -0,0 +1,189 @@
+package syntax
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/nico-ec/uwu/toml"
+)
+
+// Register all the language definitions (*.toml) found in <dir>.
+// A file that can't be loaded is skipped and its error returned
+func LoadDir(dir string) []error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lang, err := ParseLanguage(string(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		Register(lang)
+	}
+	return errs
+}
+
+// Read a language definition from the content of a TOML file.
+// Only "name" and "extensions" are required:
+//
+//	name = "Go"
+//	extensions = ["go"]
+//	keywords = ["func", "return"]
+//	types = ["int", "string"]
+//	brackets = ["()", "[]", "{}"]
+//
+//	[comments]
+//	line = "//"
+//	block = ["/*", "*/"]
+//
+//	[strings]
+//	delimiters = ['"', "'"]
+//	escape = '\'
+//	raw = ['`']
+//
+//	[numbers]
+//	prefixes = ["0x", "0b"]
+//	separator = "_"
+//	exponent = "eE"
+//	suffixes = "i"
+func ParseLanguage(source string) (Language, error) {
+	root, err := toml.Parse(source)
+	if err != nil {
+		return Language{}, err
+	}
+	d := decoder{}
+	lang := Language{
+		Name:       d.str(root, "name"),
+		Extensions: d.strs(root, "extensions"),
+		Keywords:   d.strs(root, "keywords"),
+		Types:      d.strs(root, "types"),
+	}
+	for _, pair := range d.strs(root, "brackets") {
+		runes := []rune(pair)
+		if len(runes) != 2 {
+			d.fail("brackets", "pairs of two characters")
+			continue
+		}
+		lang.Brackets = append(lang.Brackets, [2]rune{runes[0], runes[1]})
+	}
+
+	comments := d.table(root, "comments")
+	lang.LineComment = d.str(comments, "line")
+	if block := d.strs(comments, "block"); len(block) == 2 {
+		lang.BlockCommentStart, lang.BlockCommentEnd = block[0], block[1]
+	} else if block != nil {
+		d.fail("comments.block", "the start and the end of the comment")
+	}
+
+	strs := d.table(root, "strings")
+	lang.StringDelimiters = d.runes(strs, "delimiters")
+	lang.EscapeChar = d.char(strs, "escape")
+	lang.RawStringDelimiters = d.runes(strs, "raw")
+
+	numbers := d.table(root, "numbers")
+	lang.Numbers = NumberRules{
+		Prefixes:  d.strs(numbers, "prefixes"),
+		Separator: d.char(numbers, "separator"),
+		Exponent:  d.str(numbers, "exponent"),
+		Suffixes:  d.str(numbers, "suffixes"),
+	}
+
+	if d.err == nil && (lang.Name == "" || len(lang.Extensions) == 0) {
+		d.err = fmt.Errorf("a language needs a name and extensions")
+	}
+	return lang, d.err
+}
+
+// Read typed values out of a toml.Table,
+// keeping the first error it runs into
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(key string, expected string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%q should be %s", key, expected)
+	}
+}
+
+func (d *decoder) table(t toml.Table, key string) toml.Table {
+	value, exist := t[key]
+	if !exist {
+		return nil
+	}
+	table, ok := value.(toml.Table)
+	if !ok {
+		d.fail(key, "a table")
+	}
+	return table
+}
+
+func (d *decoder) str(t toml.Table, key string) string {
+	value, exist := t[key]
+	if !exist {
+		return ""
+	}
+	s, ok := value.(toml.String)
+	if !ok {
+		d.fail(key, "a string")
+	}
+	return string(s)
+}
+
+func (d *decoder) strs(t toml.Table, key string) []string {
+	value, exist := t[key]
+	if !exist {
+		return nil
+	}
+	array, ok := value.(*toml.Array)
+	if !ok {
+		d.fail(key, "an array of strings")
+		return nil
+	}
+	result := make([]string, 0, array.Len())
+	for i := 0; i < array.Len(); i += 1 {
+		s, ok := array.Get(i).(toml.String)
+		if !ok {
+			d.fail(key, "an array of strings")
+			return nil
+		}
+		result = append(result, string(s))
+	}
+	return result
+}
+
+func (d *decoder) char(t toml.Table, key string) rune {
+	s := d.str(t, key)
+	if s == "" {
+		return 0
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		d.fail(key, "a single character")
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func (d *decoder) runes(t toml.Table, key string) []rune {
+	var result []rune
+	for _, s := range d.strs(t, key) {
+		if utf8.RuneCountInString(s) != 1 {
+			d.fail(key, "an array of single characters")
+			return nil
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		result = append(result, r)
+	}
+	return result
+}
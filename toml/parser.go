@@ -3,6 +3,7 @@ package toml
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -61,7 +62,7 @@ pass:
 		}
 	}
 
-	return p.root, nil
+	return p.root, err
 }
 
 func (p *parser) consume() token {
@@ -156,6 +157,7 @@ key:
 			break key
 		default:
 			err = fmt.Errorf("invalid syntax at line %d; got %d after %d", t.line, t.kind, p.previous.kind)
+			break key
 		}
 	}
 	return k, err
@@ -164,9 +166,10 @@ key:
 func (p *parser) parseValue(valueToken token) (v Value, err error) {
 	switch valueToken.kind {
 	case tokenNumber:
-		val, e := strconv.ParseFloat(valueToken.lexeme, 64)
+		lexeme := strings.ReplaceAll(valueToken.lexeme, "_", "")
+		val, e := strconv.ParseFloat(lexeme, 64)
 		if e != nil {
-			err = e
+			err = fmt.Errorf("invalid number %q at line %d", valueToken.lexeme, valueToken.line)
 			break
 		}
 		v = Number(val)
@@ -175,11 +178,15 @@ func (p *parser) parseValue(valueToken token) (v Value, err error) {
 	case tokenTrue:
 		v = Boolean(true)
 	case tokenString:
-		v = String(valueToken.lexeme[1 : len(valueToken.lexeme)-1])
+		var str string
+		str, err = unquote(valueToken)
+		v = String(str)
 	case tokenOpenBracket:
 		v, err = p.parseArrayValue()
 	case tokenOpenBrace:
 		v, err = p.parseInlineTableValue()
+	default:
+		err = fmt.Errorf("invalid value %q at line %d", valueToken.lexeme, valueToken.line)
 	}
 
 	return v, err
@@ -194,6 +201,8 @@ lookahead:
 		switch t.kind {
 		case tokenCloseBracket:
 			break lookahead
+		case tokenEOF:
+			return nil, fmt.Errorf("unterminated array at line %d", t.line)
 		case tokenColon:
 			if p.previous.isValueKind() {
 				continue
@@ -215,7 +224,8 @@ arrayLoop:
 		switch t.kind {
 		case tokenCloseBracket:
 			break arrayLoop
-		case tokenColon:
+		case tokenColon, tokenNewline, tokenPound:
+			// Arrays can span several lines
 			continue
 		default:
 			val, err := p.parseValue(t)
@@ -236,6 +246,8 @@ tableLoop:
 		switch t.kind {
 		case tokenCloseBrace:
 			break tableLoop
+		case tokenEOF:
+			return nil, fmt.Errorf("unterminated inline table at line %d", t.line)
 		case tokenColon:
 			if p.previous.isValueKind() {
 				continue
@@ -275,3 +287,55 @@ func (k key) decl() []token {
 func (k key) name() string {
 	return k.accessors[k.count-1].lexeme
 }
+
+// Decode a string token. Literal strings ('...') are taken
+// as is, escapes are only supported in basic strings ("...")
+func unquote(t token) (string, error) {
+	content := t.lexeme[1 : len(t.lexeme)-1]
+	if t.lexeme[0] == '\'' || !strings.ContainsRune(content, '\\') {
+		return content, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(content); i += 1 {
+		c := content[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i += 1
+		switch content[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"':
+			b.WriteByte('"')
+		case '\\':
+			b.WriteByte('\\')
+		case 'u', 'U':
+			size := 4
+			if content[i] == 'U' {
+				size = 8
+			}
+			if i+1+size > len(content) {
+				return "", fmt.Errorf("invalid unicode escape at line %d", t.line)
+			}
+			code, err := strconv.ParseUint(content[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape at line %d", t.line)
+			}
+			b.WriteRune(rune(code))
+			i += size
+		default:
+			return "", fmt.Errorf("invalid escape '\\%c' at line %d", content[i], t.line)
+		}
+	}
+	return b.String(), nil
+}
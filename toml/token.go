@@ -47,13 +47,14 @@ type (
 func (l *lexer) initLexer(input string) {
 	*l = lexer{
 		source: input,
+		line:   1,
 	}
 }
 
 func (l *lexer) scanToken() token {
 	l.skipWhitespaces()
 	if l.EOF() {
-		return token{kind: tokenEOF}
+		return token{kind: tokenEOF, line: l.line}
 	}
 	t := token{
 		line:  l.line,
@@ -63,13 +64,11 @@ func (l *lexer) scanToken() token {
 	c := l.advance()
 	switch c {
 	case '#':
-		for {
-			next := l.advance()
-			if next == '\n' {
-				l.line += 1
-				break
-			}
+		// The newline ending the comment is a token of its own
+		for !l.EOF() && l.peek() != '\n' {
+			l.advance()
 		}
+		t.kind = tokenPound
 	case '.':
 		t.kind = tokenDot
 	case ',':
@@ -86,13 +85,11 @@ func (l *lexer) scanToken() token {
 		t.kind = tokenCloseBrace
 	case '\n':
 		t.kind = tokenNewline
-	case '"':
-		t.kind = tokenString
-		for {
-			next := l.advance()
-			if next == '"' {
-				break
-			}
+		l.line += 1
+	case '"', '\'':
+		// Unterminated strings are left as invalid tokens
+		if l.lexString(c) {
+			t.kind = tokenString
 		}
 	default:
 		if isLetter(c) {
@@ -102,7 +99,7 @@ func (l *lexer) scanToken() token {
 			} else {
 				t.kind = tokenIdentifier
 			}
-		} else if isNumber(c) {
+		} else if isNumber(c) || (c == '-' || c == '+') && !l.EOF() && isNumber(l.peek()) {
 			l.lexNumber()
 			t.kind = tokenNumber
 		}
@@ -112,6 +109,24 @@ func (l *lexer) scanToken() token {
 	return t
 }
 
+// Move past the closing quote of a string. Escapes are
+// only skipped here, they are decoded by the parser
+func (l *lexer) lexString(quote byte) bool {
+	for !l.EOF() {
+		switch l.advance() {
+		case quote:
+			return true
+		case '\n':
+			return false
+		case '\\':
+			if quote == '"' && !l.EOF() {
+				l.advance()
+			}
+		}
+	}
+	return false
+}
+
 func (l *lexer) EOF() bool {
 	return l.current >= len(l.source)
 }
@@ -142,7 +157,7 @@ func (l *lexer) lexIdentifer() {
 			break
 		}
 		c := l.peek()
-		if isLetter(c) {
+		if isLetter(c) || isNumber(c) || c == '-' {
 			l.advance()
 		} else {
 			break
@@ -156,10 +171,13 @@ func (l *lexer) lexNumber() {
 			break
 		}
 		c := l.peek()
-		if isNumber(c) || c == '.' {
+		switch {
+		case isNumber(c), c == '.', c == '_', c == 'e', c == 'E':
 			l.advance()
-		} else {
-			break
+		case (c == '-' || c == '+') && (l.source[l.current-1] == 'e' || l.source[l.current-1] == 'E'):
+			l.advance()
+		default:
+			return
 		}
 	}
 }
@@ -177,5 +195,5 @@ func isLetter(c byte) bool {
 }
 
 func isNumber(c byte) bool {
-	return c >= '0' && c < '9'
+	return c >= '0' && c <= '9'
 }
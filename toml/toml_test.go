@@ -103,8 +103,8 @@ func TestArrayKeyVal(t *testing.T) {
 	if array, ok := result["array"].(*Array); !ok {
 		t.Errorf(`Key "array" is not an Array; %#v`, result["array"])
 	} else {
-		if array.length() != 3 {
-			t.Errorf(`Array "array" is not of length 3, got %d`, array.length())
+		if array.Len() != 3 {
+			t.Errorf(`Array "array" is not of length 3, got %d`, array.Len())
 		}
 	}
 }
@@ -152,11 +152,11 @@ func TestArrayOfTables(t *testing.T) {
 	if array, ok := result["array"].(*Array); !ok {
 		t.Errorf(`Key "array" is not an Array; %#v`, result["array"])
 	} else {
-		if array.length() != 2 {
-			t.Errorf("Array doesn't have a length of 2, got %d", array.length())
+		if array.Len() != 2 {
+			t.Errorf("Array doesn't have a length of 2, got %d", array.Len())
 		} else {
-			fmt.Printf("%#v\n", array.get(0))
-			fmt.Printf("%#v\n", array.get(1))
+			fmt.Printf("%#v\n", array.Get(0))
+			fmt.Printf("%#v\n", array.Get(1))
 		}
 	}
 }
@@ -180,3 +180,71 @@ func TestArrayOfTables(t *testing.T) {
 // 	}
 // 	fmt.Println(result)
 // }
+
+func TestStrings(t *testing.T) {
+	input := `
+	basic = "a \"quoted\" \\ \u00e9"
+	literal = 'C:\path\'
+	quote = '"'
+	`
+	result, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]String{
+		"basic":   `a "quoted" \ é`,
+		"literal": `C:\path\`,
+		"quote":   `"`,
+	}
+	for k, v := range expected {
+		if result[k] != v {
+			t.Errorf("Key %s: expected %q, got %#v", k, v, result[k])
+		}
+	}
+}
+
+func TestMultilineArrayAndComments(t *testing.T) {
+	input := `
+	# A comment
+	array = [ # first line
+		1, 9,
+		-2.5e3, # last values
+	]
+	h1 = 1_000 # trailing comment`
+
+	result, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	array, ok := result["array"].(*Array)
+	if !ok {
+		t.Fatalf(`Key "array" is not an Array; %#v`, result["array"])
+	}
+	expected := []Number{1, 9, -2500}
+	if array.Len() != len(expected) {
+		t.Fatalf("Expected %d values, got %d", len(expected), array.Len())
+	}
+	for i, v := range expected {
+		if array.Get(i) != v {
+			t.Errorf("Value %d: expected %v, got %#v", i, v, array.Get(i))
+		}
+	}
+	if result["h1"] != Number(1000) {
+		t.Errorf("Key h1: expected 1000, got %#v", result["h1"])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	inputs := []string{
+		`a = "unterminated`,
+		`a = [1, 2`,
+		`[table`,
+		`a = "\q"`,
+		`a = `,
+	}
+	for _, input := range inputs {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
@@ -38,11 +38,11 @@ func (a *Array) appendValue(v Value) {
 	a.data = append(a.data, v)
 }
 
-func (a *Array) get(index int) Value {
+func (a *Array) Get(index int) Value {
 	return a.data[index]
 }
 
-func (a *Array) length() int {
+func (a *Array) Len() int {
 	return len(a.data)
 }
 
@@ -0,0 +1,429 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	completionMaxRows = 8
+	completionMargin  = 4
+	// Space between the text of an entry and its detail
+	completionDetailGap = 20
+)
+
+type (
+	// An entry of a CompletionPopup
+	Completion struct {
+		// Replaces the word before the caret once picked
+		Text string
+		// Drawn after the text, like the kind of the entry
+		Detail string
+	}
+
+	// Give the completions of the word before the caret of <t>.
+	//
+	// <prefix> is the part of that word already typed. The popup
+	// filters the entries as the typing goes on, and only asks again
+	// when the prefix doesn't extend the one of the last call
+	CompletionProvider interface {
+		Completions(t *TextBox, prefix string) []Completion
+	}
+
+	// A Highlighter can implement it so its keywords are offered
+	// by the WordCompletion provider
+	KeywordSource interface {
+		Keywords() []string
+	}
+
+	// A list of completions drawn at the caret of a TextBox.
+	//
+	// The TextBox gives it the up, down, enter, tab and escape keys
+	// while it is visible. It opens itself once <MinPrefix> runes of
+	// a word are typed, or when Open is called
+	CompletionPopup struct {
+		Background Background
+		Provider   CompletionProvider
+		// 0 only opens the popup with Open
+		MinPrefix int
+
+		textBox *TextBox
+		open    bool
+		// Offset of the word being completed
+		wordStart int
+		// The prefix of the last call to the provider
+		queried string
+		prefix  string
+		items   []Completion
+		matches []Completion
+
+		selected  int
+		scrollRow int
+		hovered   int
+		rect      Rectangle
+	}
+
+	// Offers the words of the TextBox, and the keywords of its
+	// highlighter if it is a KeywordSource
+	WordCompletion struct{}
+)
+
+// Show the completions of the word before the caret, even if
+// it is shorter than <MinPrefix>
+func (p *CompletionPopup) Open() {
+	t := p.textBox
+	if t == nil || p.Provider == nil || t.HasSelection() {
+		return
+	}
+	p.open = true
+	p.wordStart = t.wordStart(t.caret)
+	p.query(string(t.buf.Slice(p.wordStart, t.caret)))
+}
+
+func (p *CompletionPopup) Close() {
+	p.open = false
+	p.items = nil
+	p.matches = nil
+}
+
+// Check if the popup is drawn and takes the keys
+func (p *CompletionPopup) Visible() bool {
+	return p.open && len(p.matches) > 0
+}
+
+// Ask the provider again, when its entries changed
+// since the popup was opened
+func (p *CompletionPopup) Refresh() {
+	if p.open {
+		p.query(p.prefix)
+	}
+}
+
+// The entries matching what is typed, in the order they are shown
+func (p *CompletionPopup) Matches() []Completion {
+	return p.matches
+}
+
+// Index of the selected entry, -1 if the popup isn't visible
+func (p *CompletionPopup) Selected() int {
+	if !p.Visible() {
+		return -1
+	}
+	return p.selected
+}
+
+func (p *CompletionPopup) MoveSelection(n int) {
+	if !p.Visible() {
+		return
+	}
+	// The selection wraps around, like in most editors
+	p.selected = (p.selected + n) % len(p.matches)
+	if p.selected < 0 {
+		p.selected += len(p.matches)
+	}
+	rows := p.rowCount()
+	if p.selected < p.scrollRow {
+		p.scrollRow = p.selected
+	} else if p.selected >= p.scrollRow+rows {
+		p.scrollRow = p.selected - rows + 1
+	}
+}
+
+// Replace the word before the caret with the selected
+// entry, as a single undo step, and close the popup
+func (p *CompletionPopup) Accept() {
+	if !p.Visible() {
+		return
+	}
+	t := p.textBox
+	text := p.matches[p.selected].Text
+	t.SetSelection(p.wordStart, t.caret)
+	t.InsertSlice([]rune(text))
+	p.Close()
+}
+
+func (p *CompletionPopup) query(prefix string) {
+	p.queried = prefix
+	p.items = p.Provider.Completions(p.textBox, prefix)
+	p.filter(prefix)
+}
+
+// Keep the entries starting with <prefix>, those
+// matching its case first. The selection is reset
+func (p *CompletionPopup) filter(prefix string) {
+	p.prefix = prefix
+	p.matches = p.matches[:0]
+	lower := strings.ToLower(prefix)
+	var others []Completion
+	for _, item := range p.items {
+		switch {
+		// Nothing left to complete
+		case item.Text == prefix:
+		case strings.HasPrefix(item.Text, prefix):
+			p.matches = append(p.matches, item)
+		case strings.HasPrefix(strings.ToLower(item.Text), lower):
+			others = append(others, item)
+		}
+	}
+	p.matches = append(p.matches, others...)
+	p.selected = 0
+	p.scrollRow = 0
+}
+
+// Follow the caret of the TextBox after its update. <typed> tells
+// if a rune of a word was typed, which can open the popup
+func (p *CompletionPopup) follow(typed bool) {
+	t := p.textBox
+	if !t.focused || t.HasSelection() {
+		p.Close()
+		return
+	}
+	start := t.wordStart(t.caret)
+	prefix := string(t.buf.Slice(start, t.caret))
+	if !p.open {
+		if typed && p.MinPrefix > 0 && t.caret-start >= p.MinPrefix {
+			p.Open()
+		}
+		return
+	}
+	// The caret left the word
+	if start != p.wordStart {
+		p.Close()
+		return
+	}
+	switch {
+	case !strings.HasPrefix(strings.ToLower(prefix), strings.ToLower(p.queried)):
+		p.query(prefix)
+	case prefix != p.prefix:
+		p.filter(prefix)
+	}
+}
+
+// Handle the keys of a visible popup
+func (p *CompletionPopup) updateKeys() {
+	switch {
+	case isKeyRepeated(keyUp):
+		p.MoveSelection(-1)
+	case isKeyRepeated(keyDown):
+		p.MoveSelection(1)
+	case isKeyJustPressed(keyEnter), isKeyJustPressed(keyTab):
+		p.Accept()
+	case isKeyJustPressed(keyEsc):
+		p.Close()
+	}
+}
+
+// Handle the mouse over a visible popup. Return true if
+// the click was on the popup, so the TextBox ignores it
+func (p *CompletionPopup) updateMouse(mPos Point) bool {
+	p.hovered = -1
+	p.layout()
+	if !p.rect.pointInBounds(mPos) {
+		return false
+	}
+	if wheel := mouseWheel(); wheel[1] != 0 {
+		p.scrollRow -= int(wheel[1])
+		if max := len(p.matches) - p.rowCount(); p.scrollRow > max {
+			p.scrollRow = max
+		}
+		if p.scrollRow < 0 {
+			p.scrollRow = 0
+		}
+	}
+	row := int((mPos[1] - p.rect.Y - completionMargin) / p.rowHeight())
+	if index := p.scrollRow + row; row >= 0 && index < len(p.matches) {
+		p.hovered = index
+		if isMouseJustPressed() {
+			p.selected = index
+			p.Accept()
+		}
+	}
+	return isMouseJustPressed()
+}
+
+func (p *CompletionPopup) rowHeight() float64 {
+	return p.textBox.TextSize + 2
+}
+
+func (p *CompletionPopup) rowCount() int {
+	if len(p.matches) < completionMaxRows {
+		return len(p.matches)
+	}
+	return completionMaxRows
+}
+
+// Place the popup under the word being completed, or
+// above it if there is no room left in the TextBox
+func (p *CompletionPopup) layout() {
+	t := p.textBox
+	font, size := t.Font, t.TextSize
+	var textWidth, detailWidth float64
+	for _, item := range p.matches {
+		if w := font.MeasureText(item.Text, size)[0]; w > textWidth {
+			textWidth = w
+		}
+		if w := font.MeasureText(item.Detail, size)[0]; w > detailWidth {
+			detailWidth = w
+		}
+	}
+	width := textWidth + completionMargin*2
+	if detailWidth > 0 {
+		width += completionDetailGap + detailWidth
+	}
+	height := float64(p.rowCount())*p.rowHeight() + completionMargin*2
+
+	origin := t.lineOrigin(t.lineIndex)
+	p.rect = Rectangle{
+		X:      origin[0] + t.measureRange(t.buf.LineStart(t.lineIndex), p.wordStart) - completionMargin,
+		Y:      origin[1] + t.lineHeight(),
+		Width:  width,
+		Height: height,
+	}
+	if bottom := t.rect.Y + t.rect.Height; p.rect.Y+height > bottom && origin[1]-height >= t.rect.Y {
+		p.rect.Y = origin[1] - height
+	}
+	if right := t.rect.X + t.rect.Width; p.rect.X+width > right {
+		p.rect.X = right - width
+	}
+	if p.rect.X < t.rect.X {
+		p.rect.X = t.rect.X
+	}
+}
+
+func (p *CompletionPopup) draw(buf *renderBuffer) {
+	if !p.Visible() {
+		return
+	}
+	t := p.textBox
+	p.layout()
+	buf.addEntry(p.Background.entry(p.rect))
+	// Outlined so it stands out of the text under it
+	border := Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], rulerAlpha}
+	for _, r := range []Rectangle{
+		{X: p.rect.X, Y: p.rect.Y, Width: p.rect.Width, Height: 1},
+		{X: p.rect.X, Y: p.rect.Y + p.rect.Height - 1, Width: p.rect.Width, Height: 1},
+		{X: p.rect.X, Y: p.rect.Y, Width: 1, Height: p.rect.Height},
+		{X: p.rect.X + p.rect.Width - 1, Y: p.rect.Y, Width: 1, Height: p.rect.Height},
+	} {
+		buf.addEntry(RenderEntry{Kind: RenderRectangle, Rect: r, Clr: border})
+	}
+
+	rowHeight := p.rowHeight()
+	last := p.scrollRow + p.rowCount()
+	for i := p.scrollRow; i < last && i < len(p.matches); i += 1 {
+		item := p.matches[i]
+		rowRect := Rectangle{
+			X:      p.rect.X,
+			Y:      p.rect.Y + completionMargin + float64(i-p.scrollRow)*rowHeight,
+			Width:  p.rect.Width,
+			Height: rowHeight,
+		}
+		if i == p.selected || i == p.hovered {
+			alpha := uint8(selectionAlpha)
+			if i != p.selected {
+				alpha = highlightAlpha
+			}
+			buf.addEntry(RenderEntry{
+				Kind: RenderRectangle,
+				Rect: rowRect,
+				Clr:  Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], alpha},
+			})
+		}
+		buf.addEntry(RenderEntry{
+			Kind: RenderText,
+			Rect: Rectangle{
+				X:      rowRect.X + completionMargin,
+				Y:      rowRect.Y + 1,
+				Height: t.TextSize,
+			},
+			Clr:  t.TextClr,
+			Font: t.Font,
+			Text: item.Text,
+		})
+		if item.Detail != "" {
+			detailWidth := t.Font.MeasureText(item.Detail, t.TextSize)[0]
+			buf.addEntry(RenderEntry{
+				Kind: RenderText,
+				Rect: Rectangle{
+					X:      rowRect.X + rowRect.Width - completionMargin - detailWidth,
+					Y:      rowRect.Y + 1,
+					Height: t.TextSize,
+				},
+				Clr:  Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], rulerAlpha},
+				Font: t.Font,
+				Text: item.Detail,
+			})
+		}
+	}
+}
+
+// The words of the TextBox starting like <prefix> in alphabetical
+// order, the word being typed left out, then the keywords
+func (WordCompletion) Completions(t *TextBox, prefix string) []Completion {
+	lower := strings.ToLower(prefix)
+	matches := func(word string) bool {
+		return strings.HasPrefix(strings.ToLower(word), lower)
+	}
+
+	keywords := make(map[string]bool)
+	if source, ok := t.highlighter.(KeywordSource); ok {
+		for _, kw := range source.Keywords() {
+			if matches(kw) {
+				keywords[kw] = true
+			}
+		}
+	}
+
+	words := make(map[string]bool)
+	current := t.wordStart(t.caret)
+	runes := t.buf.Runes()
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i += 1
+			continue
+		}
+		start := i
+		for i < len(runes) && isWordRune(runes[i]) {
+			i += 1
+		}
+		// Numbers aren't worth completing
+		if unicode.IsDigit(runes[start]) || start == current && t.caret > current {
+			continue
+		}
+		if word := string(runes[start:i]); !keywords[word] && matches(word) {
+			words[word] = true
+		}
+	}
+
+	result := make([]Completion, 0, len(words)+len(keywords))
+	for _, word := range sortedWords(words) {
+		result = append(result, Completion{Text: word})
+	}
+	for _, kw := range sortedWords(keywords) {
+		result = append(result, Completion{Text: kw, Detail: "keyword"})
+	}
+	return result
+}
+
+func sortedWords(set map[string]bool) []string {
+	words := make([]string, 0, len(set))
+	for w := range set {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Offset of the start of the word ending at <offset>
+func (t *TextBox) wordStart(offset int) int {
+	start := t.buf.LineStart(t.buf.LineAt(offset))
+	for offset > start && isWordRune(t.buf.RuneAt(offset-1)) {
+		offset -= 1
+	}
+	return offset
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
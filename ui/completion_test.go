@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testKeywords struct {
+	Highlighter
+}
+
+func (testKeywords) Keywords() []string {
+	return []string{"func", "for", "return"}
+}
+
+func completionTexts(items []Completion) []string {
+	var texts []string
+	for _, item := range items {
+		texts = append(texts, item.Text)
+	}
+	return texts
+}
+
+func TestWordCompletion(t *testing.T) {
+	tb := newTestTextBox("format Foo fo42 fmt 42 forest\nfo")
+	tb.highlighter = testKeywords{}
+	tb.SetSelection(tb.Buffer().Len(), tb.Buffer().Len())
+
+	items := WordCompletion{}.Completions(tb, "fo")
+	expected := []string{"Foo", "fo42", "forest", "format", "for"}
+	if texts := completionTexts(items); !reflect.DeepEqual(texts, expected) {
+		t.Errorf("Expected %v, got %v", expected, texts)
+	}
+	if last := items[len(items)-1]; last.Detail != "keyword" {
+		t.Errorf("The keywords should be told apart, got %q", last.Detail)
+	}
+}
+
+func TestCompletionPopup(t *testing.T) {
+	tb := newTestTextBox("forest format Foo\n")
+	p := &CompletionPopup{Provider: WordCompletion{}, MinPrefix: 2}
+	tb.SetCompletion(p)
+	tb.SetSelection(tb.Buffer().Len(), tb.Buffer().Len())
+	tb.SetFocus(true)
+
+	// Typed like the TextBox update does
+	tb.InsertChar('f')
+	p.follow(true)
+	if p.Visible() {
+		t.Fatalf("The popup should wait for %d runes", p.MinPrefix)
+	}
+	tb.InsertChar('o')
+	p.follow(true)
+	if texts := completionTexts(p.Matches()); !reflect.DeepEqual(texts, []string{"forest", "format", "Foo"}) {
+		t.Fatalf("The matching case should come first, got %v", texts)
+	}
+
+	tb.InsertChar('r')
+	p.follow(true)
+	if texts := completionTexts(p.Matches()); !reflect.DeepEqual(texts, []string{"forest", "format"}) {
+		t.Fatalf("The entries should be filtered as the typing goes on, got %v", texts)
+	}
+	p.MoveSelection(1)
+	p.Accept()
+	checkText(t, tb, "forest format Foo\nformat")
+	if p.Visible() {
+		t.Errorf("The popup should close once an entry is picked")
+	}
+
+	// Picking an entry is a single undo step
+	tb.Undo()
+	checkText(t, tb, "forest format Foo\nfor")
+
+	// Leaving the word closes the popup
+	tb.SetSelection(tb.Buffer().Len(), tb.Buffer().Len())
+	p.Open()
+	if !p.Visible() {
+		t.Fatalf("Open should show the completions of %q", "for")
+	}
+	tb.InsertChar(' ')
+	p.follow(false)
+	if p.Visible() {
+		t.Errorf("The popup should close when the caret leaves the word")
+	}
+}
+
+func TestCompletionSelectionWraps(t *testing.T) {
+	tb := newTestTextBox("aa ab ac\n")
+	p := &CompletionPopup{Provider: WordCompletion{}}
+	tb.SetCompletion(p)
+	tb.SetSelection(tb.Buffer().Len(), tb.Buffer().Len())
+	tb.SetFocus(true)
+	p.Open()
+	if len(p.Matches()) != 3 {
+		t.Fatalf("An empty prefix should match every word, got %v", completionTexts(p.Matches()))
+	}
+	p.MoveSelection(-1)
+	if p.Selected() != 2 {
+		t.Errorf("Expected the last entry to be selected, got %d", p.Selected())
+	}
+	p.MoveSelection(1)
+	if p.Selected() != 0 {
+		t.Errorf("Expected the first entry to be selected, got %d", p.Selected())
+	}
+}
@@ -140,6 +140,7 @@ func (c *Context) UpdateUI(data Input) {
 		c.input.previousmLeft = c.input.mLeft
 		c.input.mPos = data.MPos
 		c.input.mLeft = data.MLeft
+		c.input.wheel = data.Wheel
 		c.input.previousKeys = c.input.keys
 		c.input.keys[keyEsc] = data.Esc
 		c.input.keys[keyEnter] = data.Enter
@@ -155,6 +156,8 @@ func (c *Context) UpdateUI(data Input) {
 		c.input.keys[keyCopy] = data.Copy
 		c.input.keys[keyCut] = data.Cut
 		c.input.keys[keyPaste] = data.Paste
+		c.input.keys[keyPageUp] = data.PageUp
+		c.input.keys[keyPageDown] = data.PageDown
 
 		for i := range c.input.keyCounts {
 			if c.input.keys[i] {
@@ -165,15 +168,52 @@ func (c *Context) UpdateUI(data Input) {
 			}
 		}
 	}
+	c.focusClickedWindow()
 	for i := 0; i < ctx.count; i += 1 {
 		c.actives[i].update()
 	}
 	c.input.pressedCharsCount = 0
 }
 
+// Move the focus to the window clicked, if it
+// is focused on click or taken back by the backdrop
+func (c *Context) focusClickedWindow() {
+	if !isMouseJustPressed() {
+		return
+	}
+	mPos := mousePosition()
+	var clicked, focused *Window
+	for _, win := range c.actives[:c.count] {
+		if !win.Active {
+			continue
+		}
+		if win.zIndex == 0 {
+			focused = win
+		}
+		if win.Rect.pointInBounds(mPos) && (clicked == nil || drawnAbove(win, clicked)) {
+			clicked = win
+		}
+	}
+	if clicked == nil || clicked == focused {
+		return
+	}
+	if clicked.FocusOnClick || clicked.Backdrop && focused != nil && focused.FocusOnClick {
+		clicked.handle.FocusWindow()
+	}
+}
+
+// Check if <a> is drawn above <b>: the backdrops first,
+// then the others from the least recently focused
+func drawnAbove(a, b *Window) bool {
+	if a.Backdrop != b.Backdrop {
+		return b.Backdrop
+	}
+	return a.zIndex < b.zIndex
+}
+
 func (c *Context) DrawUI() []RenderEntry {
 	sort.SliceStable(c.actives[:c.count], func(i, j int) bool {
-		return c.actives[i].zIndex > c.actives[j].zIndex
+		return drawnAbove(c.actives[j], c.actives[i])
 	})
 	for i := 0; i < ctx.count; i += 1 {
 		c.actives[i].draw(&c.renderBuf)
@@ -0,0 +1,37 @@
+package ui
+
+import "testing"
+
+func TestFocusOnClick(t *testing.T) {
+	c := NewContext()
+	MakeContextCurrent(c)
+	defer MakeContextCurrent(nil)
+	main := AddWindow(Window{
+		Active:   true,
+		Rect:     Rectangle{Width: 800, Height: 600},
+		Backdrop: true,
+	})
+	panel := AddWindow(Window{
+		Active:       true,
+		Rect:         Rectangle{X: 100, Y: 400, Width: 400, Height: 100},
+		FocusOnClick: true,
+	})
+	click := func(x, y float64) {
+		c.UpdateUI(Input{MPos: Point{x, y}, MLeft: true})
+		c.UpdateUI(Input{MPos: Point{x, y}})
+	}
+
+	click(50, 50)
+	if !main.IsFocused() {
+		t.Fatalf("A click next to the panel should give the focus to the backdrop")
+	}
+	// The panel is still drawn above it
+	c.DrawUI()
+	if c.actives[c.count-1] != getWindow(panel) {
+		t.Errorf("The backdrop should be drawn under the panel, even with the focus")
+	}
+	click(200, 450)
+	if !panel.IsFocused() {
+		t.Errorf("A click on the panel should give it the focus back")
+	}
+}
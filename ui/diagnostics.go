@@ -0,0 +1,304 @@
+package ui
+
+import "strings"
+
+const (
+	diagnosticMargin = 4
+	// The longest messages are cut in the tooltip
+	diagnosticMaxLines = 10
+	// Length of a step of the squiggles, in pixels
+	squiggleStep = 2
+)
+
+const (
+	DiagnosticError DiagnosticSeverity = iota
+	DiagnosticWarning
+	DiagnosticInfo
+)
+
+type (
+	DiagnosticSeverity int
+
+	// A problem found in the text of a TextBox, by
+	// a compiler or a language server for instance
+	Diagnostic struct {
+		// An empty range marks the rune at its start
+		Range    TextRange
+		Severity DiagnosticSeverity
+		Message  string
+	}
+
+	// How the diagnostics are drawn. The icons go in the ruler,
+	// a severity without icon is only underlined
+	DiagnosticStyle struct {
+		Error       Color
+		Warning     Color
+		Info        Color
+		ErrorIcon   Image
+		WarningIcon Image
+		// Behind the message of the hovered diagnostic
+		Tooltip Color
+	}
+)
+
+// Replace the diagnostics of the TextBox. Their ranges
+// follow the edits until they are set again
+func (t *TextBox) SetDiagnostics(diagnostics []Diagnostic) {
+	t.diagnostics = append(t.diagnostics[:0], diagnostics...)
+	t.hoveredDiagnostic = -1
+}
+
+func (t *TextBox) Diagnostics() []Diagnostic {
+	return t.diagnostics
+}
+
+func (t *TextBox) SetDiagnosticStyle(style DiagnosticStyle) {
+	t.diagStyle = style
+}
+
+func (s *DiagnosticStyle) colorOf(severity DiagnosticSeverity) Color {
+	switch severity {
+	case DiagnosticError:
+		return s.Error
+	case DiagnosticWarning:
+		return s.Warning
+	}
+	return s.Info
+}
+
+func (s *DiagnosticStyle) iconOf(severity DiagnosticSeverity) Image {
+	switch severity {
+	case DiagnosticError:
+		return s.ErrorIcon
+	case DiagnosticWarning:
+		return s.WarningIcon
+	}
+	return nil
+}
+
+// Move the diagnostics after <added> runes were inserted at <offset>.
+// Text typed right at the end of a range doesn't extend it
+func (t *TextBox) shiftDiagnosticsInserted(offset, added int) {
+	for i := range t.diagnostics {
+		r := &t.diagnostics[i].Range
+		empty := r.Start == r.End
+		if r.Start >= offset {
+			r.Start += added
+		}
+		if r.End > offset || empty && r.End == offset {
+			r.End += added
+		}
+	}
+}
+
+// Move the diagnostics after the runes in [start, end) were deleted
+func (t *TextBox) shiftDiagnosticsDeleted(start, end int) {
+	shift := func(offset int) int {
+		switch {
+		case offset >= end:
+			return offset - (end - start)
+		case offset > start:
+			return start
+		}
+		return offset
+	}
+	for i := range t.diagnostics {
+		r := &t.diagnostics[i].Range
+		r.Start, r.End = shift(r.Start), shift(r.End)
+	}
+}
+
+// The range of runes <d> underlines, at least one rune wide
+func (t *TextBox) diagnosticRange(d Diagnostic) (int, int) {
+	start := clampOffset(d.Range.Start, t.buf.Len())
+	end := clampOffset(d.Range.End, t.buf.Len())
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// Find the diagnostic under the mouse, either over
+// its text or over its icon in the ruler
+func (t *TextBox) updateHoveredDiagnostic(mPos Point) {
+	t.hoveredDiagnostic = -1
+	var offset int
+	switch {
+	case t.activeRect.pointInBounds(mPos):
+		ln := t.scrollLine + int((mPos[1]-t.activeRect.Y)/t.lineHeight())
+		if ln >= t.buf.LineCount() {
+			return
+		}
+		offset = t.offsetAtX(ln, mPos[0])
+		// offsetAtX rounds to the closest rune boundary
+		if x := t.lineOrigin(ln)[0] + t.measureRange(t.buf.LineStart(ln), offset); x > mPos[0] && offset > t.buf.LineStart(ln) {
+			offset -= 1
+		}
+		for i, d := range t.diagnostics {
+			if start, end := t.diagnosticRange(d); offset >= start && offset < end {
+				t.hoveredDiagnostic = i
+				return
+			}
+		}
+	case t.HasRuler && t.rulerRect.pointInBounds(mPos):
+		ln := t.scrollLine + int((mPos[1]-t.activeRect.Y)/t.lineHeight())
+		t.hoveredDiagnostic = t.lineDiagnostic(ln)
+	}
+}
+
+// Index of the most severe diagnostic starting on <ln>, -1 if none
+func (t *TextBox) lineDiagnostic(ln int) int {
+	if ln < 0 || ln >= t.buf.LineCount() {
+		return -1
+	}
+	found := -1
+	for i, d := range t.diagnostics {
+		start, _ := t.diagnosticRange(d)
+		if t.buf.LineAt(clampOffset(start, t.buf.Len())) != ln {
+			continue
+		}
+		if found < 0 || d.Severity < t.diagnostics[found].Severity {
+			found = i
+		}
+	}
+	return found
+}
+
+// Draw a squiggle under the runes of each diagnostic
+// on the lines in [first, last)
+func (t *TextBox) drawDiagnostics(buf *renderBuffer, first, last int) {
+	for _, d := range t.diagnostics {
+		start, end := t.diagnosticRange(d)
+		clr := t.diagStyle.colorOf(d.Severity)
+		startLine, endLine := t.buf.LineAt(start), t.buf.LineAt(clampOffset(end, t.buf.Len()))
+		if startLine < first {
+			startLine = first
+		}
+		if endLine >= last {
+			endLine = last - 1
+		}
+		for ln := startLine; ln <= endLine; ln += 1 {
+			lineStart, lineEnd := t.buf.LineStart(ln), t.buf.LineEnd(ln)
+			from, to := lineStart, lineEnd
+			if start > from {
+				from = start
+			}
+			if end < to {
+				to = end
+			}
+			origin := t.lineOrigin(ln)
+			x := origin[0] + t.measureRange(lineStart, from)
+			width := t.measureRange(from, to)
+			// A diagnostic at the end of a line marks its terminator
+			if width == 0 {
+				width = t.Font.GlyphAdvance(' ', t.TextSize)
+			}
+			t.drawSquiggle(buf, x, origin[1]+t.TextSize-1, width, clr)
+		}
+	}
+}
+
+func (t *TextBox) drawSquiggle(buf *renderBuffer, x, y, width float64, clr Color) {
+	left, right := t.activeRect.X, t.activeRect.X+t.activeRect.Width
+	for i := 0; float64(i)*squiggleStep < width; i += 1 {
+		stepX := x + float64(i)*squiggleStep
+		if stepX+squiggleStep <= left || stepX >= right {
+			continue
+		}
+		buf.addEntry(RenderEntry{
+			Kind: RenderRectangle,
+			Rect: Rectangle{
+				X:      stepX,
+				Y:      y + float64(i%2),
+				Width:  squiggleStep,
+				Height: 1,
+			},
+			Clr: clr,
+		})
+	}
+}
+
+// Draw the icon of the most severe diagnostic of <ln> in the ruler
+func (t *TextBox) drawDiagnosticIcon(buf *renderBuffer, ln int, origin Point) {
+	index := t.lineDiagnostic(ln)
+	if index < 0 {
+		return
+	}
+	icon := t.diagStyle.iconOf(t.diagnostics[index].Severity)
+	if icon == nil {
+		return
+	}
+	buf.addEntry(RenderEntry{
+		Kind: RenderImageFit,
+		Rect: Rectangle{
+			X:      t.rulerRect.X,
+			Y:      origin[1],
+			Width:  t.TextSize,
+			Height: t.TextSize,
+		},
+		Img: icon,
+	})
+}
+
+// Draw the message of the hovered diagnostic under its line,
+// or above it if there is no room left in the TextBox
+func (t *TextBox) drawDiagnosticTooltip(buf *renderBuffer) {
+	if t.hoveredDiagnostic < 0 || t.hoveredDiagnostic >= len(t.diagnostics) {
+		return
+	}
+	d := t.diagnostics[t.hoveredDiagnostic]
+	lines := strings.Split(strings.TrimRight(d.Message, "\n"), "\n")
+	if len(lines) > diagnosticMaxLines {
+		lines = append(lines[:diagnosticMaxLines-1], "...")
+	}
+	var width float64
+	for _, line := range lines {
+		if w := t.Font.MeasureText(line, t.TextSize)[0]; w > width {
+			width = w
+		}
+	}
+	rowHeight := t.TextSize + 2
+	rect := Rectangle{
+		Width:  width + diagnosticMargin*2,
+		Height: float64(len(lines))*rowHeight + diagnosticMargin*2,
+	}
+	start, _ := t.diagnosticRange(d)
+	ln := t.buf.LineAt(start)
+	origin := t.lineOrigin(ln)
+	rect.X = mousePosition()[0]
+	rect.Y = origin[1] + t.lineHeight()
+	if rect.Y+rect.Height > t.rect.Y+t.rect.Height && origin[1]-rect.Height >= t.rect.Y {
+		rect.Y = origin[1] - rect.Height
+	}
+	if right := t.rect.X + t.rect.Width; rect.X+rect.Width > right {
+		rect.X = right - rect.Width
+	}
+	if rect.X < t.rect.X {
+		rect.X = t.rect.X
+	}
+
+	buf.addEntry(RenderEntry{
+		Kind: RenderRectangle,
+		Rect: rect,
+		Clr:  t.diagStyle.Tooltip,
+	})
+	// The edge tells the severity
+	buf.addEntry(RenderEntry{
+		Kind: RenderRectangle,
+		Rect: Rectangle{X: rect.X, Y: rect.Y, Width: 2, Height: rect.Height},
+		Clr:  t.diagStyle.colorOf(d.Severity),
+	})
+	for i, line := range lines {
+		buf.addEntry(RenderEntry{
+			Kind: RenderText,
+			Rect: Rectangle{
+				X:      rect.X + diagnosticMargin,
+				Y:      rect.Y + diagnosticMargin + float64(i)*rowHeight,
+				Height: t.TextSize,
+			},
+			Clr:  t.TextClr,
+			Font: t.Font,
+			Text: line,
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package ui
+
+import "testing"
+
+func TestDiagnosticsFollowEdits(t *testing.T) {
+	tb := newTestTextBox("foo bar\nbaz")
+	tb.SetDiagnostics([]Diagnostic{
+		{Range: TextRange{4, 7}, Severity: DiagnosticWarning, Message: "bar"},
+		{Range: TextRange{8, 8}, Severity: DiagnosticError, Message: "baz"},
+	})
+
+	// Typed before both
+	tb.SetSelection(0, 0)
+	tb.InsertSlice([]rune("xx"))
+	if r := tb.Diagnostics()[0].Range; r != (TextRange{6, 9}) {
+		t.Errorf("Expected the range to move to [6, 9), got %v", r)
+	}
+	// Typed right after the first one, it isn't extended
+	tb.SetSelection(9, 9)
+	tb.InsertChar('!')
+	if r := tb.Diagnostics()[0].Range; r != (TextRange{6, 9}) {
+		t.Errorf("Expected the range to stay [6, 9), got %v", r)
+	}
+	if r := tb.Diagnostics()[1].Range; r != (TextRange{11, 11}) {
+		t.Errorf("Expected the empty range to move to 11, got %v", r)
+	}
+
+	// Deleting a part of the range shrinks it
+	tb.SetSelection(5, 7)
+	tb.DeleteChar()
+	checkText(t, tb, "xxfooar!\nbaz")
+	if r := tb.Diagnostics()[0].Range; r != (TextRange{5, 7}) {
+		t.Errorf("Expected the range to shrink to [5, 7), got %v", r)
+	}
+
+	tb.LoadBufferData([]rune("new"))
+	if len(tb.Diagnostics()) != 0 {
+		t.Errorf("Loading a new content should clear the diagnostics")
+	}
+}
+
+func TestLineDiagnostic(t *testing.T) {
+	tb := newTestTextBox("a b c\nd")
+	tb.SetDiagnostics([]Diagnostic{
+		{Range: TextRange{0, 1}, Severity: DiagnosticInfo},
+		{Range: TextRange{2, 3}, Severity: DiagnosticError},
+		{Range: TextRange{4, 5}, Severity: DiagnosticWarning},
+	})
+	if i := tb.lineDiagnostic(0); i != 1 {
+		t.Errorf("The icon of the line should be the one of the error, got %d", i)
+	}
+	if i := tb.lineDiagnostic(1); i != -1 {
+		t.Errorf("The second line has no diagnostic, got %d", i)
+	}
+}
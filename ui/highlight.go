@@ -0,0 +1,88 @@
+package ui
+
+const (
+	TokenNormal TokenKind = iota
+	TokenKeyword
+	TokenType
+	TokenFunction
+	TokenNumber
+	TokenString
+	TokenComment
+	// Operators and punctuation
+	TokenSymbol
+)
+
+type (
+	TokenKind uint8
+
+	// A span of a line. <Start> and <End> are rune
+	// offsets from the beginning of the line
+	Token struct {
+		Start int
+		End   int
+		Kind  TokenKind
+	}
+
+	// What a Highlighter needs to know from the previous line, when
+	// a block comment or a string spans several lines for instance.
+	// The meaning of the values is left to the Highlighter, the
+	// first line of the text always starts with 0
+	LineState int
+
+	// Split the lines of a TextBox into colored tokens.
+	//
+	// HighlightLine appends the tokens of <line> to <tokens> and
+	// returns the result with the state at the end of the line.
+	// <state> is the end state of the previous line. The tokens
+	// must be ordered and must not overlap, the runes they don't
+	// cover are drawn as TokenNormal
+	Highlighter interface {
+		HighlightLine(line []rune, state LineState, tokens []Token) ([]Token, LineState)
+	}
+
+	ColorStyle struct {
+		Normal   Color
+		Keyword  Color
+		Type     Color
+		Function Color
+		Digit    Color
+		String   Color
+		Comment  Color
+		Symbol   Color
+	}
+)
+
+func (c *ColorStyle) colorOf(kind TokenKind) Color {
+	switch kind {
+	case TokenKeyword:
+		return c.Keyword
+	case TokenType:
+		return c.Type
+	case TokenFunction:
+		return c.Function
+	case TokenNumber:
+		return c.Digit
+	case TokenString:
+		return c.String
+	case TokenComment:
+		return c.Comment
+	case TokenSymbol:
+		return c.Symbol
+	}
+	return c.Normal
+}
+
+// Use <h> to color the text, nil turns the highlighting off
+func (t *TextBox) SetHighlighter(h Highlighter) {
+	t.highlighter = h
+	t.relex(0, len(t.lines)-1)
+}
+
+// Colors of the token kinds. The text color of a
+// highlighted TextBox follows the Normal one
+func (t *TextBox) SetSyntaxColors(style ColorStyle) {
+	t.clrStyle = style
+	if t.HasSyntaxHighlight {
+		t.TextClr = style.Normal
+	}
+}
@@ -0,0 +1,173 @@
+package ui
+
+import "unicode"
+
+const historyCap = 500
+
+const (
+	editGroup editKind = iota
+	editTyping
+	editDeleting
+)
+
+type (
+	editKind int
+
+	// A single modification of the buffer.
+	// <deleted> were removed at <offset>, then <inserted> was inserted there
+	edit struct {
+		offset   int
+		deleted  []rune
+		inserted []rune
+	}
+
+	// A group of edits that is undone and redone as a whole
+	transaction struct {
+		kind         editKind
+		edits        []edit
+		caretBefore  int
+		anchorBefore int
+		caretAfter   int
+	}
+
+	history struct {
+		undos    []transaction
+		redos    []transaction
+		current  transaction
+		depth    int
+		applying bool
+	}
+)
+
+func (h *history) clear() {
+	h.undos = h.undos[:0]
+	h.redos = h.redos[:0]
+	h.current = transaction{}
+	h.depth = 0
+}
+
+func (h *history) begin(kind editKind, caret int, anchor int) {
+	if h.depth == 0 {
+		h.current = transaction{
+			kind:         kind,
+			caretBefore:  caret,
+			anchorBefore: anchor,
+		}
+	}
+	h.depth += 1
+}
+
+func (h *history) end(caret int) {
+	h.depth -= 1
+	if h.depth > 0 || len(h.current.edits) == 0 {
+		return
+	}
+	h.current.caretAfter = caret
+	h.redos = h.redos[:0]
+	if h.canMerge(h.current) {
+		top := &h.undos[len(h.undos)-1]
+		top.edits = append(top.edits, h.current.edits...)
+		top.caretAfter = h.current.caretAfter
+		return
+	}
+	if len(h.undos) >= historyCap {
+		copy(h.undos, h.undos[1:])
+		h.undos = h.undos[:len(h.undos)-1]
+	}
+	h.undos = append(h.undos, h.current)
+}
+
+func (h *history) record(e edit) {
+	if h.applying || h.depth == 0 {
+		return
+	}
+	h.current.edits = append(h.current.edits, e)
+}
+
+// Typing and deleting runs are merged into a single step,
+// a new step is started at each word boundary
+func (h *history) canMerge(tr transaction) bool {
+	if len(h.undos) == 0 || tr.kind == editGroup || len(tr.edits) != 1 {
+		return false
+	}
+	top := &h.undos[len(h.undos)-1]
+	if top.kind != tr.kind || top.caretAfter != tr.caretBefore {
+		return false
+	}
+	last := top.edits[len(top.edits)-1]
+	next := tr.edits[0]
+	switch tr.kind {
+	case editTyping:
+		if len(last.inserted) == 0 || len(next.inserted) == 0 {
+			return false
+		}
+		previous := last.inserted[len(last.inserted)-1]
+		return !(unicode.IsSpace(previous) && !unicode.IsSpace(next.inserted[0]))
+	case editDeleting:
+		if len(last.deleted) == 0 || len(next.deleted) == 0 {
+			return false
+		}
+		return !(unicode.IsSpace(last.deleted[0]) && !unicode.IsSpace(next.deleted[0]))
+	}
+	return false
+}
+
+// Group all the following edits into a single undo step
+// until the matching call to EndTransaction
+func (t *TextBox) BeginTransaction() {
+	t.history.begin(editGroup, t.caret, t.anchor)
+}
+
+func (t *TextBox) EndTransaction() {
+	t.history.end(t.caret)
+	t.anchor = t.caret
+}
+
+func (t *TextBox) Undo() {
+	if len(t.history.undos) == 0 || t.history.depth > 0 {
+		return
+	}
+	tr := t.history.undos[len(t.history.undos)-1]
+	t.history.undos = t.history.undos[:len(t.history.undos)-1]
+
+	t.history.applying = true
+	for i := len(tr.edits) - 1; i >= 0; i -= 1 {
+		e := tr.edits[i]
+		t.deleteRange(e.offset, e.offset+len(e.inserted))
+		t.insertAt(e.offset, e.deleted)
+	}
+	t.history.applying = false
+
+	t.history.redos = append(t.history.redos, tr)
+	t.caret = tr.caretBefore
+	t.anchor = tr.anchorBefore
+	t.updateCursor()
+}
+
+func (t *TextBox) Redo() {
+	if len(t.history.redos) == 0 || t.history.depth > 0 {
+		return
+	}
+	tr := t.history.redos[len(t.history.redos)-1]
+	t.history.redos = t.history.redos[:len(t.history.redos)-1]
+
+	t.history.applying = true
+	for _, e := range tr.edits {
+		t.deleteRange(e.offset, e.offset+len(e.deleted))
+		t.insertAt(e.offset, e.inserted)
+	}
+	t.history.applying = false
+
+	t.history.undos = append(t.history.undos, tr)
+	t.caret = tr.caretAfter
+	t.anchor = t.caret
+	t.updateCursor()
+}
+
+func (t *TextBox) CanUndo() bool {
+	return len(t.history.undos) > 0
+}
+
+func (t *TextBox) CanRedo() bool {
+	return len(t.history.redos) > 0
+}
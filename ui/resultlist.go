@@ -0,0 +1,195 @@
+package ui
+
+const resultListInitialCap = 64
+
+type (
+	// A flat and scrollable list of single line entries.
+	//
+	// Unlike List, the entries don't need unique names and
+	// can be appended while the list is displayed, which fits
+	// search results streamed in over several frames
+	ResultList struct {
+		widgetRoot
+
+		Background Background
+		Style      Style
+		Font       Font
+		TextSize   float64
+		TextClr    Color
+		Receiver   ResultListReceiver
+
+		activeRect Rectangle
+		items      []string
+		selected   int
+		hovered    int
+		scrollLine int
+		scrollbar  Scrollbar
+	}
+
+	ResultListReceiver interface {
+		OnResultSelected(index int)
+	}
+)
+
+func (r *ResultList) init() {
+	r.activeRect = Rectangle{
+		X:      r.rect.X + r.Style.Margin[0],
+		Y:      r.rect.Y + r.Style.Margin[1],
+		Width:  r.rect.Width - r.Style.Margin[0]*2 - scrollbarWidth,
+		Height: r.rect.Height - r.Style.Margin[1]*2,
+	}
+	r.scrollbar.setRect(Rectangle{
+		X:      r.rect.X + r.rect.Width - scrollbarWidth,
+		Y:      r.rect.Y,
+		Width:  scrollbarWidth,
+		Height: r.rect.Height,
+	})
+	if r.items == nil {
+		r.items = make([]string, 0, resultListInitialCap)
+	}
+	r.hovered = -1
+}
+
+func (r *ResultList) update(parentFocused bool) {
+	r.scrollbar.Clr = r.TextClr
+	r.scrollbar.SetContent(float64(len(r.items)), float64(r.visibleCount()), float64(r.scrollLine))
+	r.scrollbar.update(parentFocused)
+	r.scrollLine = int(r.scrollbar.Offset())
+
+	r.hovered = -1
+	mPos := mousePosition()
+	if !r.activeRect.pointInBounds(mPos) {
+		return
+	}
+	if wheel := mouseWheel(); wheel[1] != 0 {
+		r.scrollTo(r.scrollLine - int(wheel[1])*scrollSpeed)
+	}
+	index := r.scrollLine + int((mPos[1]-r.activeRect.Y)/r.rowHeight())
+	if index < len(r.items) {
+		r.hovered = index
+		if isMouseJustPressed() {
+			r.selected = index
+			r.Activate()
+		}
+	}
+}
+
+func (r *ResultList) draw(buf *renderBuffer) {
+	buf.addEntry(r.Background.entry(r.rect))
+
+	rowHeight := r.rowHeight()
+	last := r.scrollLine + r.visibleCount()
+	if last > len(r.items) {
+		last = len(r.items)
+	}
+	for i := r.scrollLine; i < last; i += 1 {
+		rowRect := Rectangle{
+			X:      r.activeRect.X,
+			Y:      r.activeRect.Y + float64(i-r.scrollLine)*rowHeight,
+			Width:  r.activeRect.Width,
+			Height: rowHeight,
+		}
+		if i == r.selected || i == r.hovered {
+			alpha := uint8(selectionAlpha)
+			if i != r.selected {
+				alpha = highlightAlpha
+			}
+			buf.addEntry(RenderEntry{
+				Kind: RenderRectangle,
+				Rect: rowRect,
+				Clr:  Color{r.TextClr[0], r.TextClr[1], r.TextClr[2], alpha},
+			})
+		}
+		buf.addEntry(RenderEntry{
+			Kind: RenderText,
+			Rect: Rectangle{
+				X:      rowRect.X,
+				Y:      rowRect.Y + 1,
+				Height: r.TextSize,
+			},
+			Clr:  r.TextClr,
+			Font: r.Font,
+			Text: r.fitText(r.items[i], rowRect.Width),
+		})
+	}
+	r.scrollbar.draw(buf)
+}
+
+// Cut the text so it doesn't overflow the list
+func (r *ResultList) fitText(text string, width float64) string {
+	var xptr float64
+	for i, c := range text {
+		xptr += r.Font.GlyphAdvance(c, r.TextSize)
+		if xptr > width {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+func (r *ResultList) rowHeight() float64 {
+	return r.TextSize + 2
+}
+
+func (r *ResultList) visibleCount() int {
+	return int(r.activeRect.Height / r.rowHeight())
+}
+
+func (r *ResultList) scrollTo(line int) {
+	max := len(r.items) - r.visibleCount()
+	if line > max {
+		line = max
+	}
+	if line < 0 {
+		line = 0
+	}
+	r.scrollLine = line
+}
+
+// Add entries at the end of the list
+func (r *ResultList) AddItems(items ...string) {
+	r.items = append(r.items, items...)
+}
+
+// Replace all the entries. The selection goes back to the first one
+func (r *ResultList) SetItems(items []string) {
+	r.items = append(r.items[:0], items...)
+	r.selected = 0
+	r.scrollLine = 0
+}
+
+func (r *ResultList) Clear() {
+	r.SetItems(nil)
+}
+
+func (r *ResultList) Len() int {
+	return len(r.items)
+}
+
+// Index of the selected entry, -1 if the list is empty
+func (r *ResultList) Selected() int {
+	if len(r.items) == 0 {
+		return -1
+	}
+	return r.selected
+}
+
+// Move the selection by <n> entries and keep it in view
+func (r *ResultList) MoveSelection(n int) {
+	if len(r.items) == 0 {
+		return
+	}
+	r.selected = clampOffset(r.selected+n, len(r.items)-1)
+	if r.selected < r.scrollLine {
+		r.scrollTo(r.selected)
+	} else if visible := r.visibleCount(); r.selected >= r.scrollLine+visible {
+		r.scrollTo(r.selected - visible + 1)
+	}
+}
+
+// Send the selected entry to the receiver
+func (r *ResultList) Activate() {
+	if r.Receiver != nil && r.Selected() >= 0 {
+		r.Receiver.OnResultSelected(r.selected)
+	}
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testResultReceiver struct {
+	selected int
+}
+
+func (r *testResultReceiver) OnResultSelected(index int) {
+	r.selected = index
+}
+
+func TestResultListSelection(t *testing.T) {
+	receiver := &testResultReceiver{selected: -1}
+	r := &ResultList{
+		Font:     testFont{},
+		TextSize: 10,
+		Receiver: receiver,
+	}
+	// Room for 10 rows of 12 pixels
+	r.setRect(Rectangle{Width: 200, Height: 120})
+	r.init()
+	if r.Selected() != -1 {
+		t.Fatalf("Nothing should be selected in an empty list, got %d", r.Selected())
+	}
+
+	for i := 0; i < 30; i += 1 {
+		r.AddItems(fmt.Sprint("result ", i))
+	}
+	r.MoveSelection(15)
+	if r.Selected() != 15 {
+		t.Fatalf("Expected entry 15 to be selected, got %d", r.Selected())
+	}
+	if r.scrollLine != 6 {
+		t.Errorf("The selection should be scrolled into view, got line %d", r.scrollLine)
+	}
+	r.MoveSelection(100)
+	if r.Selected() != 29 {
+		t.Errorf("The selection should stop on the last entry, got %d", r.Selected())
+	}
+	r.Activate()
+	if receiver.selected != 29 {
+		t.Errorf("The receiver should get the selected entry, got %d", receiver.selected)
+	}
+
+	r.SetItems([]string{"a", "b"})
+	if r.Selected() != 0 || r.scrollLine != 0 {
+		t.Errorf("Replacing the entries should reset the selection and the view")
+	}
+}
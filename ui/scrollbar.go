@@ -0,0 +1,113 @@
+package ui
+
+const (
+	scrollbarWidth   = 8
+	scrollbarMinSize = 20
+	scrollbarAlpha   = 60
+)
+
+// A vertical scrollbar driven by the widget it scrolls.
+//
+// The owner gives the length of its content, the visible length and the
+// current offset with SetContent, then reads the new offset back after
+// update since the user can drag the thumb or click on the track.
+type Scrollbar struct {
+	widgetRoot
+
+	Clr Color
+
+	content  float64
+	view     float64
+	offset   float64
+	dragging bool
+	grab     float64
+}
+
+func (s *Scrollbar) SetContent(content, view, offset float64) {
+	s.content = content
+	s.view = view
+	s.offset = offset
+}
+
+func (s *Scrollbar) Offset() float64 {
+	return s.offset
+}
+
+// Check if the content is longer than the visible part
+func (s *Scrollbar) Scrollable() bool {
+	return s.content > s.view
+}
+
+func (s *Scrollbar) update(parentFocused bool) {
+	if !s.Scrollable() {
+		s.dragging = false
+		return
+	}
+	mPos := mousePosition()
+	thumb := s.thumbRect()
+	if isMouseJustPressed() && s.rect.pointInBounds(mPos) {
+		if thumb.pointInBounds(mPos) {
+			s.dragging = true
+			s.grab = mPos[1] - thumb.Y
+		} else if mPos[1] < thumb.Y {
+			s.setOffset(s.offset - s.view)
+		} else {
+			s.setOffset(s.offset + s.view)
+		}
+	}
+	if s.dragging {
+		if !isMousePressed() {
+			s.dragging = false
+			return
+		}
+		track := s.rect.Height - thumb.Height
+		if track > 0 {
+			ratio := (mPos[1] - s.grab - s.rect.Y) / track
+			s.setOffset(ratio * (s.content - s.view))
+		}
+	}
+}
+
+func (s *Scrollbar) draw(buf *renderBuffer) {
+	if !s.Scrollable() {
+		return
+	}
+	buf.addEntry(RenderEntry{
+		Kind: RenderRectangle,
+		Rect: s.rect,
+		Clr:  Color{s.Clr[0], s.Clr[1], s.Clr[2], scrollbarAlpha},
+	})
+	buf.addEntry(RenderEntry{
+		Kind: RenderRectangle,
+		Rect: s.thumbRect(),
+		Clr:  s.Clr,
+	})
+}
+
+func (s *Scrollbar) setOffset(offset float64) {
+	max := s.content - s.view
+	switch {
+	case offset > max:
+		offset = max
+	case offset < 0:
+		offset = 0
+	}
+	s.offset = offset
+}
+
+func (s *Scrollbar) thumbRect() Rectangle {
+	height := s.rect.Height * (s.view / s.content)
+	if height < scrollbarMinSize {
+		height = scrollbarMinSize
+	}
+	y := s.rect.Y
+	if s.content > s.view {
+		y += (s.rect.Height - height) * (s.offset / (s.content - s.view))
+	}
+	return Rectangle{
+		X:      s.rect.X,
+		Y:      y,
+		Width:  s.rect.Width,
+		Height: height,
+	}
+}
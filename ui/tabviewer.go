@@ -119,6 +119,16 @@ func (t *TabViewer) SetActiveTab(name string) {
 	}
 }
 
+// Same as SetActiveTab but for tabs sharing the same name
+func (t *TabViewer) SetActiveWidget(w Widget) {
+	for i := 0; i < t.tabCount; i += 1 {
+		tab := t.tabs[i]
+		if tab.widget == w {
+			t.currentTab = tab
+		}
+	}
+}
+
 func (t *TabViewer) ActiveTab() Widget {
 	return t.currentTab.widget
 }
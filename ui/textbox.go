@@ -2,7 +2,6 @@ package ui
 
 import (
 	"fmt"
-	"log"
 )
 
 // Everything here is a little experimental and prototypish
@@ -14,6 +13,10 @@ const (
 	blinkTime             = 45
 	rulerWidth            = 40
 	rulerAlpha            = 155
+	selectionAlpha        = 80
+	highlightAlpha        = 40
+	multiClickTime        = 15
+	scrollSpeed           = 3
 )
 
 type (
@@ -22,21 +25,30 @@ type (
 
 		Background Background
 		focused    bool
-		// This should be a dynamic array
-		// in the off-chance that the edited text
-		// is incredibly long and its size can't be
-		// accounted beforehand
+		// Initial capacity of the text buffer.
+		// The buffer grows past it when needed
 		Cap             int
-		charBuf         []rune
-		charCount       int
+		buf             TextBuffer
 		lines           []line
-		lineCount       int
 		caret           int
 		lineIndex       int
-		currentLine     *line
-		currentIndent   int
 		lineRenderCount int
 
+		// The viewport. <scrollLine> is the first visible line
+		// and <scrollX> the horizontal offset in pixels
+		scrollLine   int
+		scrollX      float64
+		HasScrollbar bool
+		scrollbar    Scrollbar
+
+		// The selection goes from the anchor to the caret.
+		// Nothing is selected when both are equal
+		anchor     int
+		dragging   bool
+		clickCount int
+		clickTimer int
+		clickPos   int
+
 		activeRect  Rectangle
 		Margin      float64
 		LinePadding float64
@@ -59,44 +71,63 @@ type (
 		Clipboard    Clipboard
 
 		HasSyntaxHighlight bool
-		lexer              lexer
+		highlighter        Highlighter
 		clrStyle           ColorStyle
+		// Reused to collect the tokens of the highlighter
+		spans []Token
+
+		history history
+		// Incremented on every edit of the buffer
+		revision int
+
+		highlights []TextRange
+
+		diagnostics       []Diagnostic
+		diagStyle         DiagnosticStyle
+		hoveredDiagnostic int
+
+		completion *CompletionPopup
 	}
 
-	ColorStyle struct {
-		Normal  Color
-		Keyword Color
-		Digit   Color
+	// A [Start, End) range of offsets in the buffer
+	TextRange struct {
+		Start int
+		End   int
 	}
 
+	// The lexing cache of a line of the buffer.
+	// The offsets of the line are kept by the TextBuffer
 	line struct {
-		id   int
-		text string
-		// A sub slice of the backing buffer
-		// with a record of the start and end in
-		// the editor's buffer
-		start     int
-		end       int
-		indentEnd int
-
 		tokens []token
 		count  int
-		// For graphical display
-		origin Point
+		// The state the line was lexed with, and the
+		// one the next line should be lexed with
+		startState LineState
+		endState   LineState
 	}
 )
 
 func (t *TextBox) init() {
-	t.charBuf = make([]rune, t.Cap)
-	t.lines = make([]line, initialLineBufferSize)
+	t.buf = NewTextBuffer(t.Cap)
+	t.lines = make([]line, 1, initialLineBufferSize)
 	t.activeRect = Rectangle{
 		X:      t.rect.X + t.Margin,
 		Y:      t.rect.Y + t.Margin,
 		Width:  t.rect.Width - t.Margin*2,
 		Height: t.rect.Height - t.Margin*2,
 	}
+	if t.HasScrollbar {
+		t.activeRect.Width -= scrollbarWidth
+		t.scrollbar.setRect(Rectangle{
+			X:      t.rect.X + t.rect.Width - scrollbarWidth,
+			Y:      t.rect.Y,
+			Width:  scrollbarWidth,
+			Height: t.rect.Height,
+		})
+	}
 	if t.HasRuler {
 		t.activeRect.X += t.Margin + rulerWidth
+		t.activeRect.Width -= t.Margin + rulerWidth
 		t.rulerRect = Rectangle{
 			X:      t.rect.X + t.Margin,
 			Y:      t.rect.Y + t.Margin,
@@ -104,29 +135,25 @@ func (t *TextBox) init() {
 			Height: t.rect.Height - (t.Margin * 2),
 		}
 	}
-	t.lines[0] = line{
-		id:    0,
-		text:  fmt.Sprint(1),
-		start: 0,
-		end:   0,
-		origin: Point{
-			t.activeRect.X,
-			t.activeRect.Y,
-		},
-	}
 	t.lines[0].tokens = make([]token, initialTokenCap)
 	if t.HasSyntaxHighlight {
 		t.TextClr = t.clrStyle.Normal
 	}
+	t.caret = 0
 	t.lineIndex = 0
-	t.currentLine = &t.lines[0]
-	t.lineCount += 1
+	t.scrollLine = 0
+	t.scrollX = 0
+	t.hoveredDiagnostic = -1
 
 	t.cursor = Rectangle{
 		X: t.activeRect.X, Y: t.activeRect.Y,
 		Width: textCursorWidth, Height: t.TextSize,
 	}
-	t.lineRenderCount = int(t.activeRect.Height/t.TextSize) + 2
+	// Only the lines fully visible are rendered
+	t.lineRenderCount = int(t.activeRect.Height / t.lineHeight())
+	if t.lineRenderCount < 1 {
+		t.lineRenderCount = 1
+	}
 }
 
 func (t *TextBox) update(parentFocused bool) {
@@ -140,56 +167,96 @@ func (t *TextBox) update(parentFocused bool) {
 	} else {
 		setCursorShape(CursorShapeDefault)
 	}
-	if isMouseJustPressed() {
+	if t.rect.pointInBounds(mPos) {
+		if wheel := mouseWheel(); wheel[0] != 0 || wheel[1] != 0 {
+			t.scrollBy(-int(wheel[1]*scrollSpeed), -wheel[0]*scrollSpeed*t.TextSize)
+		}
+	}
+	if t.HasScrollbar {
+		t.scrollbar.Clr = t.TextClr
+		t.scrollbar.SetContent(float64(t.buf.LineCount()), float64(t.lineRenderCount), float64(t.scrollLine))
+		t.scrollbar.update(parentFocused)
+		if ln := int(t.scrollbar.Offset()); ln != t.scrollLine {
+			t.scrollBy(ln-t.scrollLine, 0)
+		}
+	}
+
+	t.updateHoveredDiagnostic(mPos)
+
+	// A click on the completion popup picks an entry
+	popupClicked := t.completion != nil && t.completion.Visible() && t.completion.updateMouse(mPos)
+
+	t.clickTimer += 1
+	if isMouseJustPressed() && !popupClicked {
 		if inBoxBounds {
 			if !t.focused {
 				t.focused = parentFocused
 			}
-			t.moveCursorToMouse(mPos)
-		} else {
+			t.onMousePressed(mPos)
+		} else if !(t.HasScrollbar && t.scrollbar.rect.pointInBounds(mPos)) {
 			t.focused = false
 		}
 	}
+	if t.dragging {
+		if isMouseJustReleased() || !isMousePressed() {
+			t.dragging = false
+		} else {
+			t.caret = t.offsetAtMouse(mPos)
+			t.updateCursor()
+		}
+	}
+	typed := false
 	if t.focused {
 		if isAnyKeyPressed([]key{keyUp, keyDown, keyRight, keyLeft}) {
 			t.showCursor = true
 			t.blinkTimer = 0
 		}
 
+		// The popup takes up, down, enter and tab while visible
+		popupKeys := t.completion != nil && t.completion.Visible()
+		if popupKeys {
+			t.completion.updateKeys()
+		}
+
 		keys := pressedChars()
 		if len(keys) > 0 {
 			for _, k := range keys {
 				t.InsertChar(k)
+				typed = isWordRune(k)
 			}
 		}
 		if isKeyRepeated(keyDelete) {
 			if isKeyRepeated(keyCtlr) {
 				// delete word
 			} else {
-				if t.caret == t.currentLine.indentEnd {
-					t.deleteIndent()
-				} else {
-					t.DeleteChar()
-				}
+				t.DeleteChar()
 			}
 		}
-		if isKeyRepeated(keyEnter) && t.Multiline {
+		if isKeyRepeated(keyEnter) && t.Multiline && !popupKeys {
 			t.insertLine()
 		}
-		if isKeyRepeated(keyTab) {
+		if isKeyRepeated(keyTab) && t.Multiline && !popupKeys {
 			t.insertIndent()
 		}
-		if isKeyRepeated(keyPaste) && t.HasClipboard {
-			data := t.Clipboard.ReadClipboard()
-			t.InsertSlice([]rune(data))
+		if t.HasClipboard {
+			switch {
+			case isKeyRepeated(keyPaste):
+				data := t.Clipboard.ReadClipboard()
+				t.InsertSlice([]rune(data))
+			case isKeyJustPressed(keyCopy):
+				t.Copy()
+			case isKeyJustPressed(keyCut):
+				t.Cut()
+			}
 		}
 
-		// Cursor movement
+		// Cursor movement. Holding shift extends the selection
+		moved := true
 		switch {
-		case isKeyRepeated(keyUp):
+		case isKeyRepeated(keyUp) && !popupKeys:
 			t.moveCursorUp()
 
-		case isKeyRepeated(keyDown):
+		case isKeyRepeated(keyDown) && !popupKeys:
 			t.moveCursorDown()
 
 		case isKeyRepeated(keyLeft):
@@ -205,6 +272,18 @@ func (t *TextBox) update(parentFocused bool) {
 			} else {
 				t.moveCursorRight()
 			}
+
+		case isKeyRepeated(keyPageUp):
+			t.moveCursorByPage(-1)
+
+		case isKeyRepeated(keyPageDown):
+			t.moveCursorByPage(1)
+
+		default:
+			moved = false
+		}
+		if moved && !isKeyPressed(keyShift) {
+			t.ClearSelection()
 		}
 
 		// Cursor blink
@@ -214,18 +293,23 @@ func (t *TextBox) update(parentFocused bool) {
 			t.showCursor = !t.showCursor
 		}
 	}
+	if t.completion != nil {
+		t.completion.follow(typed)
+	}
 }
 
 func (t *TextBox) draw(buf *renderBuffer) {
 	bgEntry := t.Background.entry(t.rect)
 	buf.addEntry(bgEntry)
 
-	if t.ShowCurrentLine {
+	first, last := t.visibleLines()
+	if t.ShowCurrentLine && t.lineIndex >= first && t.lineIndex < last {
+		origin := t.lineOrigin(t.lineIndex)
 		buf.addEntry(RenderEntry{
 			Kind: RenderRectangle,
 			Rect: Rectangle{
-				X:      t.currentLine.origin[0],
-				Y:      t.currentLine.origin[1],
+				X:      t.activeRect.X,
+				Y:      origin[1],
 				Width:  t.activeRect.Width,
 				Height: t.TextSize,
 			},
@@ -233,61 +317,45 @@ func (t *TextBox) draw(buf *renderBuffer) {
 		})
 	}
 
-	lCount := t.lineRenderCount
-	if lCount > t.lineCount {
-		lCount = t.lineCount
+	for _, h := range t.highlights {
+		t.drawRange(buf, h.Start, h.End, first, last, highlightAlpha)
 	}
-	for i := 0; i < lCount; i += 1 {
+	if t.HasSelection() {
+		start, end := t.Selection()
+		t.drawRange(buf, start, end, first, last, selectionAlpha)
+	}
+	for i := first; i < last; i += 1 {
 		line := &t.lines[i]
+		origin := t.lineOrigin(i)
+		runes := t.buf.Slice(t.buf.LineStart(i), t.buf.LineEnd(i))
 		var xptr float64 = 0
 		for j := 0; j < line.count; j += 1 {
-			var clr Color
 			token := line.tokens[j]
-			text := string(t.charBuf[line.start+token.start : line.start+token.end])
-			switch t.HasSyntaxHighlight {
-			case true:
-				switch token.kind {
-				case tokenIdentifier:
-					clr = t.clrStyle.Normal
-				case tokenKeyword:
-					clr = t.clrStyle.Keyword
-				case tokenNumber:
-					clr = t.clrStyle.Digit
-				default:
-					clr = t.clrStyle.Normal
-				}
-			case false:
-				clr = t.TextClr
+			clr := t.TextClr
+			if t.HasSyntaxHighlight {
+				clr = t.clrStyle.colorOf(token.kind)
 			}
-			buf.addEntry(RenderEntry{
-				Kind: RenderText,
-				Rect: Rectangle{
-					X:      line.origin[0] + xptr,
-					Y:      line.origin[1],
-					Height: t.TextSize,
-				},
-				Clr:  clr,
-				Font: t.Font,
-				Text: text,
-			})
+			t.drawClippedText(buf, runes[token.start:token.end], Point{origin[0] + xptr, origin[1]}, token.width, clr)
 			xptr += token.width
 		}
 		if t.HasRuler {
-
-			lnWidth := t.Font.MeasureText(line.text, t.TextSize)
+			t.drawDiagnosticIcon(buf, i, origin)
+			lnText := fmt.Sprint(i + 1)
+			lnWidth := t.Font.MeasureText(lnText, t.TextSize)
 			buf.addEntry(RenderEntry{
 				Kind: RenderText,
 				Rect: Rectangle{
 					X:      t.rulerRect.X + t.rulerRect.Width - lnWidth[0] - t.Margin,
-					Y:      t.rulerRect.Y + (t.TextSize+t.LinePadding)*float64(i),
+					Y:      origin[1],
 					Height: t.TextSize,
 				},
 				Clr:  Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], rulerAlpha},
 				Font: t.Font,
-				Text: line.text,
+				Text: lnText,
 			})
 		}
 	}
+	t.drawDiagnostics(buf, first, last)
 	if t.HasRuler {
 		buf.addEntry(RenderEntry{
 			Kind: RenderRectangle,
@@ -300,346 +368,639 @@ func (t *TextBox) draw(buf *renderBuffer) {
 			Clr: Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], rulerAlpha},
 		})
 	}
-	if t.showCursor && t.focused {
+	if t.HasScrollbar {
+		t.scrollbar.draw(buf)
+	}
+	cursorVisible := t.lineIndex >= first && t.lineIndex < last &&
+		t.cursor.X >= t.activeRect.X && t.cursor.X <= t.activeRect.X+t.activeRect.Width
+	if t.showCursor && t.focused && cursorVisible {
 		buf.addEntry(RenderEntry{
 			Kind: RenderRectangle,
 			Rect: t.cursor,
 			Clr:  t.TextClr,
 		})
 	}
+	if t.completion != nil {
+		t.completion.draw(buf)
+	}
+	if t.completion == nil || !t.completion.Visible() {
+		t.drawDiagnosticTooltip(buf)
+	}
 }
 
-func (t *TextBox) InsertChar(r rune) {
-	copy(t.charBuf[t.caret+1:], t.charBuf[t.caret:t.charCount])
-	t.charBuf[t.caret] = r
-	t.charCount += 1
-	t.currentLine.end += 1
-	for i := t.currentLine.id + 1; i < t.lineCount; i += 1 {
-		t.lines[i].start += 1
-		t.lines[i].end += 1
-	}
-	t.cursor.X += t.Font.GlyphAdvance(r, t.TextSize)
-	t.caret += 1
-
-	t.lexLine(t.currentLine)
+// Draw the runes starting at the given position. The runes
+// outside of the horizontal bounds of the text area are left out
+func (t *TextBox) drawClippedText(buf *renderBuffer, runes []rune, pos Point, width float64, clr Color) {
+	left, right := t.activeRect.X, t.activeRect.X+t.activeRect.Width
+	if pos[0]+width <= left || pos[0] >= right {
+		return
+	}
+	start, end := 0, len(runes)
+	if pos[0] < left || pos[0]+width > right {
+		xptr := pos[0]
+		start = end
+		for i, r := range runes {
+			advance := t.glyphAdvance(r)
+			if xptr < left {
+				pos[0] += advance
+			} else if start == end {
+				start = i
+			}
+			if xptr+advance > right {
+				end = i
+				break
+			}
+			xptr += advance
+		}
+		if start >= end {
+			return
+		}
+	}
+	buf.addEntry(RenderEntry{
+		Kind: RenderText,
+		Rect: Rectangle{
+			X:      pos[0],
+			Y:      pos[1],
+			Height: t.TextSize,
+		},
+		Clr:  clr,
+		Font: t.Font,
+		Text: string(runes[start:end]),
+	})
 }
 
-func (t *TextBox) DeleteChar() {
-	if t.charCount > 0 && t.caret > 0 {
-		r := t.charBuf[t.caret-1]
-		if t.caret < t.charCount {
-			copy(t.charBuf[t.caret-1:], t.charBuf[t.caret:t.charCount])
+// Highlight the runes in [start, end)
+// on the lines in [first, last)
+func (t *TextBox) drawRange(buf *renderBuffer, start, end int, first, last int, alpha uint8) {
+	if end > t.buf.Len() {
+		return
+	}
+	startLine, endLine := t.buf.LineAt(start), t.buf.LineAt(end)
+	if startLine < first {
+		startLine = first
+	}
+	if endLine >= last {
+		endLine = last - 1
+	}
+	for i := startLine; i <= endLine; i += 1 {
+		lineStart, lineEnd := t.buf.LineStart(i), t.buf.LineEnd(i)
+		from, to := lineStart, lineEnd
+		if start > from {
+			from = start
 		}
-		for i := t.currentLine.id + 1; i < t.lineCount; i += 1 {
-			t.lines[i].start -= 1
-			t.lines[i].end -= 1
+		if end < to {
+			to = end
 		}
-		t.currentLine.end -= 1
-		t.caret -= 1
-		t.charCount -= 1
-		if t.currentLine.end < t.currentLine.start {
-			t.deleteLine()
-		} else {
-			t.cursor.X -= t.Font.GlyphAdvance(r, t.TextSize)
+		origin := t.lineOrigin(i)
+		x := origin[0] + t.measureRange(lineStart, from)
+		width := t.measureRange(from, to)
+		// Show the selected line terminator as a space
+		if end > lineEnd && i < t.buf.LineCount()-1 {
+			width += t.Font.GlyphAdvance(' ', t.TextSize)
+		}
+		// Clip the highlight to the text area
+		if x < t.activeRect.X {
+			width -= t.activeRect.X - x
+			x = t.activeRect.X
 		}
+		if right := t.activeRect.X + t.activeRect.Width; x+width > right {
+			width = right - x
+		}
+		if width <= 0 {
+			continue
+		}
+		buf.addEntry(RenderEntry{
+			Kind: RenderRectangle,
+			Rect: Rectangle{
+				X:      x,
+				Y:      origin[1],
+				Width:  width,
+				Height: t.TextSize,
+			},
+			Clr: Color{t.TextClr[0], t.TextClr[1], t.TextClr[2], alpha},
+		})
 	}
+}
 
-	t.lexLine(t.currentLine)
+// Typing replaces the selection if there is one
+func (t *TextBox) InsertChar(r rune) {
+	kind := editTyping
+	if t.HasSelection() {
+		kind = editGroup
+	}
+	t.history.begin(kind, t.caret, t.anchor)
+	t.deleteSelection()
+	t.insertAt(t.caret, []rune{r})
+	t.caret += 1
+	t.anchor = t.caret
+	t.history.end(t.caret)
+	t.updateCursor()
 }
 
-// Doesn't handle new lines and such
-func (t *TextBox) InsertSlice(data []rune) {
-	length := len(data)
-	copy(t.charBuf[t.caret+length:], t.charBuf[t.caret:t.charCount])
-
-	subLength := 0
-	var current int
-	var c rune
-	for {
-		if current >= length {
-			t.currentLine.end += subLength
-			t.charCount += subLength
-			t.lexLine(t.currentLine)
-			for i := t.currentLine.id + 1; i < t.lineCount; i += 1 {
-				t.lines[i].start += subLength
-				t.lines[i].end += subLength
-			}
-			break
-		}
-		c = data[current]
-		current += 1
-		if c == '\r' && data[current] == '\n' {
-			current += 1
-			t.currentLine.end += subLength
-			t.charCount += subLength
-			t.lexLine(t.currentLine)
-			for i := t.currentLine.id + 1; i < t.lineCount; i += 1 {
-				t.lines[i].start += subLength
-				t.lines[i].end += subLength
-			}
-			subLength = 0
-			t.insertLine()
-			continue
-		}
-		t.charBuf[t.caret+current-1] = c
-		subLength += 1
+// Delete the rune before the caret,
+// or the selected runes if there are some
+func (t *TextBox) DeleteChar() {
+	if t.HasSelection() {
+		t.history.begin(editGroup, t.caret, t.anchor)
+		t.deleteSelection()
+		t.history.end(t.caret)
+		t.updateCursor()
+		return
 	}
-	t.cursor.Y = t.currentLine.origin[1]
-	for i := 0; i < subLength; i += 1 {
-		t.cursor.X += t.Font.GlyphAdvance(t.charBuf[t.caret+i], t.TextSize)
+	if t.caret > 0 {
+		start := t.caret - 1
+		t.history.begin(editDeleting, t.caret, t.anchor)
+		t.deleteRange(start, t.caret)
+		t.caret = start
+		t.anchor = t.caret
+		t.history.end(t.caret)
+		t.updateCursor()
 	}
-	t.caret += length
 }
 
-func (t *TextBox) insertNewline() {
-	copy(t.charBuf[t.caret+2:], t.charBuf[t.caret:t.charCount])
-	t.charBuf[t.caret] = '\r'
-	t.charBuf[t.caret+1] = '\n'
-	t.charCount += 2
-	for i := t.currentLine.id + 1; i < t.lineCount; i += 1 {
-		t.lines[i].start += 2
-		t.lines[i].end += 2
+// Insert the runes at the caret. Any kind of line
+// terminator is stored as a single '\n'
+func (t *TextBox) InsertSlice(data []rune) {
+	data = normalizeNewlines(data)
+	t.history.begin(editGroup, t.caret, t.anchor)
+	t.deleteSelection()
+	t.insertAt(t.caret, data)
+	t.caret += len(data)
+	t.anchor = t.caret
+	t.history.end(t.caret)
+	t.updateCursor()
+}
+
+// Write the selection to the clipboard,
+// or the current line if nothing is selected
+func (t *TextBox) Copy() {
+	if !t.HasClipboard {
+		return
 	}
+	start, end := t.copyRange()
+	t.Clipboard.WriteClipboard(string(t.buf.Slice(start, end)))
 }
 
-func (t *TextBox) insertIndent() {
-	// copy(t.charBuf[t.caret+t.TabSize:], t.charBuf[t.caret:t.charCount])
-	if t.caret == t.currentLine.indentEnd {
-		t.currentIndent += 1
-		t.currentLine.indentEnd += 1
-		// t.currentLine.indent += 1
+// Copy then delete the selection, or the current line
+// if nothing is selected
+func (t *TextBox) Cut() {
+	if !t.HasClipboard {
+		return
 	}
-	t.InsertChar('\t')
+	start, end := t.copyRange()
+	t.Clipboard.WriteClipboard(string(t.buf.Slice(start, end)))
+
+	t.history.begin(editGroup, t.caret, t.anchor)
+	t.deleteRange(start, end)
+	t.caret = start
+	t.anchor = start
+	t.history.end(t.caret)
+	t.updateCursor()
 }
 
-func (t *TextBox) deleteIndent() {
-	if t.currentLine.start == t.currentLine.indentEnd {
-		t.DeleteChar()
+func (t *TextBox) copyRange() (start, end int) {
+	if t.HasSelection() {
+		return t.Selection()
+	}
+	start = t.buf.LineStart(t.lineIndex)
+	if t.lineIndex+1 < t.buf.LineCount() {
+		end = t.buf.LineStart(t.lineIndex + 1)
 	} else {
-		t.currentIndent -= 1
-		t.currentLine.indentEnd -= 1
-		t.DeleteChar()
+		end = t.buf.LineEnd(t.lineIndex)
 	}
+	return start, end
 }
 
-func (t *TextBox) insertLine() {
-	t.insertNewline()
-	newlineStart := t.caret + 2
-	newlineEnd := t.currentLine.end + 2
-	t.currentLine.end = t.caret
-	t.lexLine(t.currentLine)
-
-	// Move all the line by one to make room for the new line
-	t.addLine()
-	for i := t.lineCount - 1; i >= t.lineIndex+2; i -= 1 {
-		t.lines[i] = t.lines[i-1]
-		t.lines[i].id += 1
-		t.lines[i].text = fmt.Sprint(i + 1)
-		t.lines[i].origin[1] += t.TextSize + t.LinePadding
-	}
-	t.lineIndex += 1
-	t.currentLine = &t.lines[t.lineIndex]
-	t.lines[t.lineIndex] = line{
-		id:        t.lineIndex,
-		text:      fmt.Sprint(t.lineIndex + 1),
-		start:     newlineStart,
-		end:       newlineEnd,
-		indentEnd: newlineStart,
-		origin: Point{
-			t.lines[t.lineIndex-1].origin[0],
-			t.lines[t.lineIndex-1].origin[1] + t.TextSize + t.LinePadding,
-		},
+// Remove the selected runes and leave the caret
+// where they were. Return false if nothing was selected
+func (t *TextBox) deleteSelection() bool {
+	if !t.HasSelection() {
+		return false
 	}
-	t.currentLine.tokens = make([]token, initialTokenCap)
-	t.MoveCursorLineStart()
+	start, end := t.Selection()
+	t.deleteRange(start, end)
+	t.caret = start
+	t.anchor = start
+	return true
+}
+
+func (t *TextBox) insertIndent() {
+	t.InsertChar('\t')
+}
+
+func (t *TextBox) insertLine() {
+	newline := []rune{'\n'}
 	if t.AutoIndent {
-		for i := 0; i < t.currentIndent; i += 1 {
-			t.InsertChar('\t')
-			t.currentLine.indentEnd += 1
+		start := t.buf.LineStart(t.lineIndex)
+		for i := start; i < t.caret; i += 1 {
+			r := t.buf.RuneAt(i)
+			if r != '\t' && r != ' ' {
+				break
+			}
+			newline = append(newline, r)
 		}
 	}
-	t.lexLine(t.currentLine)
+	t.InsertSlice(newline)
 }
 
-// Do we assume that the carret is on the deleted line? (???)
-func (t *TextBox) deleteLine() {
-	// FIXME: This is not correct. Can end up out of bounds
-	for i := t.lineIndex; i < t.lineCount; i += 1 {
-		t.lines[i] = t.lines[i+1]
-		t.lines[i].id -= 1
-		t.lines[i].origin[1] -= t.TextSize + t.LinePadding
+// Insert the runes at the given offset and keep the lexing cache
+// in sync. The caret is left untouched.
+//
+// Every edit of the buffer goes through here or deleteRange
+// so it can be recorded in the history
+func (t *TextBox) insertAt(offset int, data []rune) {
+	if len(data) == 0 {
+		return
+	}
+	t.history.record(edit{
+		offset:   offset,
+		inserted: append([]rune(nil), data...),
+	})
+	t.revision += 1
+	t.shiftDiagnosticsInserted(offset, len(data))
+	ln := t.buf.LineAt(offset)
+	count := t.buf.LineCount()
+	t.buf.Insert(offset, data)
+	added := t.buf.LineCount() - count
+	t.spliceLines(ln+1, 0, added)
+	t.relex(ln, ln+added)
+}
+
+// Delete the runes in [start, end) and keep the lexing cache
+// in sync. The caret is left untouched
+func (t *TextBox) deleteRange(start, end int) {
+	if start >= end {
+		return
 	}
-	t.lineIndex -= 1
-	t.lineCount -= 1
-	t.currentLine = &t.lines[t.lineIndex]
-	t.MoveCursorLineEnd()
+	t.history.record(edit{
+		offset:  start,
+		deleted: t.buf.Slice(start, end),
+	})
+	t.revision += 1
+	t.shiftDiagnosticsDeleted(start, end)
+	ln := t.buf.LineAt(start)
+	count := t.buf.LineCount()
+	t.buf.Delete(start, end)
+	removed := count - t.buf.LineCount()
+	t.spliceLines(ln+1, removed, 0)
+	t.relex(ln, ln)
 }
 
-func (t *TextBox) addLine() {
-	if t.lineCount >= len(t.lines) {
-		newbuf := make([]line, len(t.lines)*2)
-		copy(newbuf[:], t.lines[:len(t.lines)])
+// Remove <removed> lines of the cache at the given index
+// and insert <added> empty ones in their place
+func (t *TextBox) spliceLines(at, removed, added int) {
+	if removed == 0 && added == 0 {
+		return
+	}
+	count := len(t.lines) - removed + added
+	if count > cap(t.lines) {
+		newbuf := make([]line, len(t.lines), count*2)
+		copy(newbuf, t.lines)
 		t.lines = newbuf
 	}
-	t.lineCount += 1
+	tail := t.lines[at+removed:]
+	t.lines = t.lines[:count]
+	copy(t.lines[at+added:], tail)
+	for i := at; i < at+added; i += 1 {
+		t.lines[i] = line{
+			tokens: make([]token, initialTokenCap),
+		}
+	}
 }
 
 func (t *TextBox) moveCursorUp() {
 	if t.lineIndex > 0 {
-		col := t.caret - t.currentLine.start
-		t.lineIndex -= 1
-		t.currentLine = &t.lines[t.lineIndex]
-		if t.currentLine.start+col < t.currentLine.end {
-			t.caret = t.currentLine.start + col
-		} else {
-			t.MoveCursorLineEnd()
-		}
-		t.cursor.Y = t.currentLine.origin[1]
+		col := t.caret - t.buf.LineStart(t.lineIndex)
+		t.moveCursorToColumn(t.lineIndex-1, col)
 	}
 }
 
 func (t *TextBox) moveCursorDown() {
-	if t.lineIndex < t.lineCount-1 {
-		col := t.caret - t.currentLine.start
-		t.lineIndex += 1
-		t.currentLine = &t.lines[t.lineIndex]
-		if t.currentLine.start+col < t.currentLine.end {
-			t.caret = t.currentLine.start + col
-		} else {
-			t.MoveCursorLineEnd()
-		}
-		t.cursor.Y = t.currentLine.origin[1]
+	if t.lineIndex < t.buf.LineCount()-1 {
+		col := t.caret - t.buf.LineStart(t.lineIndex)
+		t.moveCursorToColumn(t.lineIndex+1, col)
 	}
 }
 
+func (t *TextBox) moveCursorToColumn(ln int, col int) {
+	start, end := t.buf.LineStart(ln), t.buf.LineEnd(ln)
+	t.caret = start + col
+	if t.caret > end {
+		t.caret = end
+	}
+	t.updateCursor()
+}
+
+// Move the caret and the view by a page in the given direction
+func (t *TextBox) moveCursorByPage(dir int) {
+	col := t.caret - t.buf.LineStart(t.lineIndex)
+	ln := t.lineIndex + dir*t.lineRenderCount
+	switch {
+	case ln < 0:
+		ln = 0
+	case ln >= t.buf.LineCount():
+		ln = t.buf.LineCount() - 1
+	}
+	t.scrollBy(dir*t.lineRenderCount, 0)
+	t.moveCursorToColumn(ln, col)
+}
+
 func (t *TextBox) moveCursorRight() {
-	if t.caret+1 <= t.charCount {
-		if t.caret+1 > t.currentLine.end {
-			t.lineIndex += 1
-			t.currentLine = &t.lines[t.lineIndex]
-			t.MoveCursorLineStart()
+	if t.caret < t.buf.Len() {
+		if t.caret >= t.buf.LineEnd(t.lineIndex) {
+			t.caret = t.buf.LineStart(t.lineIndex + 1)
 		} else {
-			c := t.charBuf[t.caret]
-			t.cursor.X += t.Font.GlyphAdvance(c, t.TextSize)
 			t.caret += 1
 		}
+		t.updateCursor()
 	}
 }
 
 func (t *TextBox) moveCursorLeft() {
-	if t.caret-1 >= 0 {
-		if t.caret-1 < t.currentLine.start {
-			t.lineIndex -= 1
-			t.currentLine = &t.lines[t.lineIndex]
-			t.MoveCursorLineEnd()
-		} else if t.caret > 0 {
-			c := t.charBuf[t.caret-1]
-			t.cursor.X -= t.Font.GlyphAdvance(c, t.TextSize)
+	if t.caret > 0 {
+		if t.caret <= t.buf.LineStart(t.lineIndex) {
+			t.caret = t.buf.LineEnd(t.lineIndex - 1)
+		} else {
 			t.caret -= 1
 		}
+		t.updateCursor()
 	}
 }
 
 func (t *TextBox) moveCursorToNextWord() {
-	if t.caret+1 <= t.charCount {
-		if t.caret+1 > t.currentLine.end {
-			t.lineIndex += 1
-			t.currentLine = &t.lines[t.lineIndex]
-			t.MoveCursorLineStart()
+	if t.caret < t.buf.Len() {
+		if t.caret >= t.buf.LineEnd(t.lineIndex) {
+			t.caret = t.buf.LineStart(t.lineIndex + 1)
+			t.updateCursor()
 			return
 		}
-		c := t.charBuf[t.caret]
-		if isTerminalSymbol(c) {
-			t.cursor.X += t.Font.GlyphAdvance(c, t.TextSize)
+		if isTerminalSymbol(t.buf.RuneAt(t.caret)) {
 			t.caret += 1
 		}
 	}
-	for t.caret+1 <= t.charCount {
-		c := t.charBuf[t.caret]
-		if isTerminalSymbol(c) {
+	end := t.buf.LineEnd(t.lineIndex)
+	for t.caret < end {
+		if isTerminalSymbol(t.buf.RuneAt(t.caret)) {
 			break
 		}
-		t.cursor.X += t.Font.GlyphAdvance(c, t.TextSize)
 		t.caret += 1
 	}
+	t.updateCursor()
 }
 
 func (t *TextBox) moveCursorToPreviousWord() {
-	if t.caret-1 >= 0 {
+	start := t.buf.LineStart(t.lineIndex)
+	if t.caret > 0 {
 		// Check if already at line start, if so go to previous line
-		if t.caret-1 < t.currentLine.start {
-			t.lineIndex -= 1
-			t.currentLine = &t.lines[t.lineIndex]
-			t.MoveCursorLineEnd()
+		if t.caret <= start {
+			t.caret = t.buf.LineEnd(t.lineIndex - 1)
+			t.updateCursor()
 			return
 		}
 		// Consume the first terminal symbol so the input doesn't get
 		// eaten by whitespaces and such.
-		c := t.charBuf[t.caret-1]
-		if isTerminalSymbol(c) {
-			t.cursor.X -= t.Font.GlyphAdvance(c, t.TextSize)
+		if isTerminalSymbol(t.buf.RuneAt(t.caret - 1)) {
 			t.caret -= 1
 		}
 	}
 	// Then move to the next word
-	for t.caret-1 >= 0 {
-		c := t.charBuf[t.caret-1]
-		if isTerminalSymbol(c) {
+	for t.caret > start {
+		if isTerminalSymbol(t.buf.RuneAt(t.caret - 1)) {
 			break
 		}
-		t.cursor.X -= t.Font.GlyphAdvance(c, t.TextSize)
 		t.caret -= 1
 	}
+	t.updateCursor()
 }
 
-func (t *TextBox) moveCursorToMouse(mPos Point) {
-	relPos := mPos[1] - t.activeRect.Y
-	t.lineIndex = int(relPos / (t.TextSize + t.LinePadding))
-	if t.lineIndex >= 0 && t.lineIndex < t.lineCount {
-		t.currentLine = &t.lines[t.lineIndex]
-
-		// Search for the correct rune to position the cursor to
-		selectedLine := &t.lines[t.lineIndex]
-		currentXStartPos := selectedLine.origin[0]
-		currentXEndPos := currentXStartPos
-		t.MoveCursorLineStart()
-		for j := selectedLine.start; j < selectedLine.end; j += 1 {
-			advance := t.Font.GlyphAdvance(t.charBuf[j], t.TextSize)
-			currentXEndPos += advance
-			if mPos[0] >= currentXStartPos && mPos[0] <= currentXEndPos {
-				break
-			}
-			t.caret = j + 1
-			t.cursor.X += advance
-			currentXStartPos = currentXEndPos
+func (t *TextBox) onMousePressed(mPos Point) {
+	offset := t.offsetAtMouse(mPos)
+	if t.clickTimer <= multiClickTime && offset == t.clickPos {
+		t.clickCount = t.clickCount%3 + 1
+	} else {
+		t.clickCount = 1
+	}
+	t.clickTimer = 0
+	t.clickPos = offset
+
+	switch t.clickCount {
+	case 1:
+		t.caret = offset
+		if !isKeyPressed(keyShift) {
+			t.anchor = t.caret
 		}
+		t.dragging = true
+	case 2:
+		t.selectWord(offset)
+	case 3:
+		t.selectLine(t.buf.LineAt(offset))
+	}
+	t.updateCursor()
+}
+
+func (t *TextBox) offsetAtMouse(mPos Point) int {
+	relPos := mPos[1] - t.activeRect.Y
+	ln := t.scrollLine + int(relPos/t.lineHeight())
+	switch {
+	case relPos < 0:
+		return 0
+	case ln >= t.buf.LineCount():
+		return t.buf.Len()
+	}
+	return t.offsetAtX(ln, mPos[0])
+}
+
+func (t *TextBox) selectWord(offset int) {
+	start, end := offset, offset
+	lineStart, lineEnd := t.buf.LineStart(t.buf.LineAt(offset)), t.buf.LineEnd(t.buf.LineAt(offset))
+	for start > lineStart && !isTerminalSymbol(t.buf.RuneAt(start-1)) {
+		start -= 1
+	}
+	for end < lineEnd && !isTerminalSymbol(t.buf.RuneAt(end)) {
+		end += 1
+	}
+	t.anchor = start
+	t.caret = end
+}
+
+// Select the whole line, its terminator included
+func (t *TextBox) selectLine(ln int) {
+	t.anchor = t.buf.LineStart(ln)
+	if ln+1 < t.buf.LineCount() {
+		t.caret = t.buf.LineStart(ln + 1)
 	} else {
-		t.lineIndex = t.lineCount - 1
-		t.currentLine = &t.lines[t.lineIndex]
-		t.MoveCursorLineEnd()
+		t.caret = t.buf.LineEnd(ln)
+	}
+}
+
+// Search for the offset of the rune under the given x position
+func (t *TextBox) offsetAtX(ln int, x float64) int {
+	start, end := t.buf.LineStart(ln), t.buf.LineEnd(ln)
+	xptr := t.lineOrigin(ln)[0]
+	for i := start; i < end; i += 1 {
+		advance := t.glyphAdvance(t.buf.RuneAt(i))
+		if x <= xptr+advance/2 {
+			return i
+		}
+		xptr += advance
+	}
+	return end
+}
+
+// Recompute the current line and the
+// graphical position of the cursor from the caret
+func (t *TextBox) updateCursor() {
+	t.lineIndex = t.buf.LineAt(t.caret)
+	t.scrollToCaret()
+	t.placeCursor()
+}
+
+// Move the graphical cursor without scrolling
+func (t *TextBox) placeCursor() {
+	origin := t.lineOrigin(t.lineIndex)
+	t.cursor.X = origin[0] + t.measureRange(t.buf.LineStart(t.lineIndex), t.caret)
+	t.cursor.Y = origin[1]
+}
+
+// Scroll the view just enough to have the caret visible
+func (t *TextBox) scrollToCaret() {
+	switch {
+	case t.lineIndex < t.scrollLine:
+		t.scrollLine = t.lineIndex
+	case t.lineIndex >= t.scrollLine+t.lineRenderCount:
+		t.scrollLine = t.lineIndex - t.lineRenderCount + 1
+	}
+	x := t.measureRange(t.buf.LineStart(t.lineIndex), t.caret)
+	switch {
+	case x < t.scrollX:
+		t.scrollX = x
+	case x+textCursorWidth > t.scrollX+t.activeRect.Width:
+		t.scrollX = x + textCursorWidth - t.activeRect.Width
+	}
+}
+
+// Scroll the view by a number of lines and pixels. The caret is left in place
+func (t *TextBox) scrollBy(lines int, x float64) {
+	t.scrollLine += lines
+	if max := t.buf.LineCount() - t.lineRenderCount; t.scrollLine > max {
+		t.scrollLine = max
+	}
+	if t.scrollLine < 0 {
+		t.scrollLine = 0
+	}
+	t.scrollX += x
+	if t.scrollX < 0 {
+		t.scrollX = 0
+	}
+	t.placeCursor()
+}
+
+// The range [first, last) of lines currently on screen
+func (t *TextBox) visibleLines() (first, last int) {
+	first = t.scrollLine
+	last = first + t.lineRenderCount
+	if last > t.buf.LineCount() {
+		last = t.buf.LineCount()
+	}
+	return first, last
+}
+
+func (t *TextBox) lineHeight() float64 {
+	return t.TextSize + t.LinePadding
+}
+
+// A tab is drawn as wide as <TabSize> spaces
+func (t *TextBox) glyphAdvance(r rune) float64 {
+	if r == '\t' && t.TabSize > 0 {
+		return float64(t.TabSize) * t.Font.GlyphAdvance(' ', t.TextSize)
+	}
+	return t.Font.GlyphAdvance(r, t.TextSize)
+}
+
+// Change the width of the tabs, the cached widths are measured again
+func (t *TextBox) SetTabSize(size int) {
+	if size == t.TabSize {
+		return
+	}
+	t.TabSize = size
+	t.relex(0, len(t.lines)-1)
+}
+
+// Width of the runes in [start, end)
+func (t *TextBox) measureRange(start, end int) float64 {
+	var advance float64
+	for i := start; i < end; i += 1 {
+		advance += t.glyphAdvance(t.buf.RuneAt(i))
+	}
+	return advance
+}
+
+func (t *TextBox) lineOrigin(ln int) Point {
+	return Point{
+		t.activeRect.X - t.scrollX,
+		t.activeRect.Y + t.lineHeight()*float64(ln-t.scrollLine),
 	}
 }
 
+// The selection anchor is left in place by the cursor movements,
+// ClearSelection has to be called to collapse it
 func (t *TextBox) MoveCursorLineStart() {
-	t.caret = t.currentLine.start
-	t.cursor.X = t.currentLine.origin[0]
-	t.cursor.Y = t.currentLine.origin[1]
+	t.caret = t.buf.LineStart(t.lineIndex)
+	t.updateCursor()
 }
 
 func (t *TextBox) MoveCursorLineEnd() {
-	t.caret = t.currentLine.end
-	var lineAdvance float64
-	for i := t.currentLine.start; i < t.currentLine.end; i += 1 {
-		lineAdvance += t.Font.GlyphAdvance(t.charBuf[i], t.TextSize)
+	t.caret = t.buf.LineEnd(t.lineIndex)
+	t.updateCursor()
+}
+
+// Move the caret to <line> (starting at 1, like CurrentLine) and
+// <col> (like CurrentColumn), both clamped to the text. A line out
+// of the view is scrolled to its middle
+func (t *TextBox) JumpTo(line, col int) {
+	ln := clampOffset(line-1, t.buf.LineCount()-1)
+	if col < 0 {
+		col = 0
+	}
+	offset := t.buf.LineStart(ln) + col
+	if end := t.buf.LineEnd(ln); offset > end {
+		offset = end
+	}
+	if ln < t.scrollLine || ln >= t.scrollLine+t.lineRenderCount {
+		t.scrollLine = ln - t.lineRenderCount/2
+		if max := t.buf.LineCount() - t.lineRenderCount; t.scrollLine > max {
+			t.scrollLine = max
+		}
+		if t.scrollLine < 0 {
+			t.scrollLine = 0
+		}
 	}
-	t.cursor.X = t.currentLine.origin[0] + lineAdvance
-	t.cursor.Y = t.currentLine.origin[1]
+	t.SetSelection(offset, offset)
 }
 
 func (t *TextBox) SetFocus(f bool) {
 	t.focused = f
 }
 
+func (t *TextBox) IsFocused() bool {
+	return t.focused
+}
+
+// Use <p> to complete the words typed, nil removes the popup
+func (t *TextBox) SetCompletion(p *CompletionPopup) {
+	if t.completion != nil {
+		t.completion.Close()
+	}
+	t.completion = p
+	if p != nil {
+		p.textBox = t
+	}
+}
+
+func (t *TextBox) Completion() *CompletionPopup {
+	return t.completion
+}
+
 func (t *TextBox) SetClipboardCallback(c Clipboard) {
 	t.HasClipboard = true
 	t.Clipboard = c
@@ -650,238 +1011,217 @@ func (t *TextBox) CurrentLine() int {
 }
 
 func (t *TextBox) CurrentColumn() int {
-	return t.caret - t.currentLine.start
+	return t.caret - t.buf.LineStart(t.lineIndex)
+}
+
+// Offset of the caret in the buffer
+func (t *TextBox) Caret() int {
+	return t.caret
+}
+
+func (t *TextBox) HasSelection() bool {
+	return t.anchor != t.caret
+}
+
+// The selected range as [start, end) offsets
+func (t *TextBox) Selection() (start, end int) {
+	if t.anchor < t.caret {
+		return t.anchor, t.caret
+	}
+	return t.caret, t.anchor
+}
+
+func (t *TextBox) SelectedText() string {
+	start, end := t.Selection()
+	return string(t.buf.Slice(start, end))
+}
+
+// Select the runes from anchor to caret. The caret is placed on <caret>
+func (t *TextBox) SetSelection(anchor, caret int) {
+	t.anchor = clampOffset(anchor, t.buf.Len())
+	t.caret = clampOffset(caret, t.buf.Len())
+	t.updateCursor()
+}
+
+func (t *TextBox) ClearSelection() {
+	t.anchor = t.caret
+}
+
+// Incremented each time the content changes.
+// Used to know when a cached result is outdated
+func (t *TextBox) Revision() int {
+	return t.revision
+}
+
+// Set the ranges drawn in the background of the text,
+// search matches for instance. Passing nil removes them
+func (t *TextBox) SetHighlights(ranges []TextRange) {
+	t.highlights = ranges
+}
+
+// Read access to the underlying text storage.
+//
+// WARNING: Edits must go through the TextBox so
+// the lines and the caret stay in sync
+func (t *TextBox) Buffer() *TextBuffer {
+	return &t.buf
 }
 
 func (t *TextBox) GetCharBuffer() []rune {
-	return t.charBuf[:t.charCount]
+	return t.buf.Runes()
 }
 
+// Replace the content of the TextBox. "\r\n" and '\r' line
+// terminators are converted to '\n'
 func (t *TextBox) LoadBufferData(data []rune) error {
-	if len(data) > t.Cap {
-		log.SetPrefix("[UI Debug]: ")
-		log.Println("Given file is too big compared to the TextBox capacity")
-		return fmt.Errorf("given file is too big")
-	}
-	t.charCount = len(data)
-	t.lineCount = 0
-	t.lineIndex = 0
+	t.buf.Reset(normalizeNewlines(data))
+	t.revision += 1
+	t.lines = t.lines[:0]
+	t.spliceLines(0, 0, t.buf.LineCount())
+	t.relex(0, len(t.lines)-1)
 	t.caret = 0
+	t.anchor = 0
+	t.dragging = false
+	t.scrollLine = 0
+	t.scrollX = 0
+	t.updateCursor()
+	t.history.clear()
+	// They were found in the previous content
+	t.SetDiagnostics(nil)
+	return nil
+}
 
-	t.lines[0] = line{
-		id:    0,
-		text:  fmt.Sprint(1),
-		start: 0,
-		end:   0,
-		origin: Point{
-			t.activeRect.X,
-			t.activeRect.Y,
-		},
-		tokens: make([]token, initialTokenCap),
+// Replace the content of the TextBox as a single undo step, with
+// a formatted version of it for instance. Only the runes that changed
+// are replaced, so the caret and the view stay where they were as much
+// as possible. "\r\n" and '\r' line terminators are converted to '\n'
+func (t *TextBox) ReplaceBufferData(data []rune) {
+	data = normalizeNewlines(data)
+	old := t.buf.Runes()
+	prefix := 0
+	for prefix < len(old) && prefix < len(data) && old[prefix] == data[prefix] {
+		prefix += 1
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(data)-prefix &&
+		old[len(old)-1-suffix] == data[len(data)-1-suffix] {
+		suffix += 1
+	}
+	oldEnd, newEnd := len(old)-suffix, len(data)-suffix
+	if prefix == oldEnd && prefix == newEnd {
+		return
 	}
-	t.lineCount += 1
 
-	var current int = 0
-	var c rune
-	for {
-		if current >= len(data) {
-			break
+	caret := t.caret
+	ln, col := t.lineIndex, t.CurrentColumn()
+	scrollLine, scrollX := t.scrollLine, t.scrollX
+
+	t.history.begin(editGroup, t.caret, t.anchor)
+	t.deleteRange(prefix, oldEnd)
+	t.insertAt(prefix, data[prefix:newEnd])
+	switch {
+	case caret >= oldEnd:
+		caret += newEnd - oldEnd
+	// Inside of the replaced runes, the caret keeps its line and column
+	case caret > prefix:
+		ln = clampOffset(ln, t.buf.LineCount()-1)
+		caret = t.buf.LineStart(ln) + col
+		if end := t.buf.LineEnd(ln); caret > end {
+			caret = end
 		}
-		c = data[current]
-		t.charBuf[current] = c
-		current += 1
-		if c == '\r' && data[current] == '\n' {
-			t.charBuf[current] = '\n'
-			current += 1
-			i := current
-
-			t.addLine()
-			t.lineIndex += 1
-			t.lines[t.lineIndex] = line{
-				id:        t.lineIndex,
-				text:      fmt.Sprint(t.lineIndex + 1),
-				start:     i,
-				end:       i,
-				indentEnd: i,
-				origin: Point{
-					t.lines[t.lineIndex-1].origin[0],
-					t.lines[t.lineIndex-1].origin[1] + t.TextSize + t.LinePadding,
-				},
-				tokens: make([]token, initialTokenCap),
-			}
-			continue
-		}
-		t.lines[t.lineIndex].end += 1
 	}
-	t.lineIndex = 0
-	t.currentLine = &t.lines[t.lineIndex]
-	for i := 0; i < t.lineCount; i += 1 {
-		t.lexLine(&t.lines[i])
-	}
-	return nil
+	t.caret = caret
+	t.anchor = caret
+	t.history.end(t.caret)
+
+	t.scrollLine = scrollLine
+	t.scrollX = scrollX
+	t.scrollBy(0, 0)
+	t.updateCursor()
 }
 
 func (t *TextBox) EmptyCharBuffer() {
-	t.charCount = 0
-	t.caret = 0
-	t.lineCount = 1
-	t.currentLine = &t.lines[0]
-	t.lineIndex = 0
-	t.lines[0] = line{
-		id:    0,
-		text:  fmt.Sprint(1),
-		start: 0,
-		end:   0,
-		origin: Point{
-			t.activeRect.X,
-			t.activeRect.Y,
-		},
-	}
-	t.lines[0].tokens = make([]token, initialTokenCap)
-	t.cursor = Rectangle{
-		X: t.activeRect.X, Y: t.activeRect.Y,
-		Width: textCursorWidth, Height: t.TextSize,
-	}
+	t.LoadBufferData(nil)
 }
 
 //
 // Lexing
 //
 
-const (
-	tokenNewline tokenKind = iota
-	tokenWhitespace
-	tokenKeyword
-	tokenIdentifier
-	tokenNumber
-	// Maybe need more granularity?
-	tokenSymbol // =+-/*%()
-)
-
 type (
-	lexer struct {
-		input    []rune
-		start    int
-		current  int
-		keywords []string
-	}
-
-	tokenKind uint8
-
+	// A token of a line with its width, so
+	// the line can be drawn without measuring it
 	token struct {
 		start int
 		end   int
 		width float64
-		kind  tokenKind
+		kind  TokenKind
 	}
 )
 
-func (t *TextBox) SetLexKeywords(kw []string) {
-	t.lexer.keywords = kw
-}
-
-func (t *TextBox) SetSyntaxColors(style ColorStyle) {
-	t.clrStyle = style
+// Lex the lines in [first, last], then the following ones until
+// they start with the same state as the last time they were lexed
+func (t *TextBox) relex(first, last int) {
+	for ln := first; ln < len(t.lines); ln += 1 {
+		if ln > last && t.lines[ln].startState == t.startState(ln) {
+			break
+		}
+		t.lexLine(ln)
+	}
 }
 
-func (t *TextBox) lexInit(start int, substr []rune) {
-	t.lexer.input = substr
-	t.lexer.start = start
-	t.lexer.current = 0
+func (t *TextBox) startState(ln int) LineState {
+	if ln == 0 {
+		return 0
+	}
+	return t.lines[ln-1].endState
 }
 
-func (t *TextBox) lexLine(l *line) {
+func (t *TextBox) lexLine(ln int) {
+	l := &t.lines[ln]
 	l.emptyTokens()
-	t.lexInit(
-		l.start,
-		t.charBuf[l.start:l.end],
-	)
-
-lex:
-	for {
-		if t.lexer.eof() {
-			break lex
-		}
-		tok := token{
-			start: t.lexer.current,
-		}
-		start := t.lexer.current
-		c := t.lexer.advance()
-		switch c {
-		case '\n', '\r':
-			tok.kind = tokenNewline
-
-		case ' ':
-			wCount := 1
-			for {
-				if t.lexer.eof() {
-					break
-				}
-				next := t.lexer.peek()
-				if next != ' ' {
-					break
-				}
-				t.lexer.advance()
-				wCount += 1
-			}
-			tok.kind = tokenWhitespace
+	l.startState = t.startState(ln)
+	l.endState = l.startState
+	runes := t.buf.Slice(t.buf.LineStart(ln), t.buf.LineEnd(ln))
 
-		default:
-			switch {
-			case isDigit(c):
-				for {
-					if t.lexer.eof() {
-						break
-					}
-					next := t.lexer.peek()
-					hasDecimal := false
-					if !isDigit(next) {
-						if next == '.' && !hasDecimal {
-							hasDecimal = true
-						} else {
-							break
-						}
-					}
-					t.lexer.advance()
-				}
-				tok.kind = tokenNumber
-
-			case isLetter(c):
-				for {
-					if t.lexer.eof() {
-						break
-					}
-					next := t.lexer.peek()
-					if !isLetter(next) {
-						break
-					}
-					t.lexer.advance()
-				}
-				word := t.lexer.input[start:t.lexer.current]
-				if t.lexer.isKeyword(string(word)) {
-					tok.kind = tokenKeyword
-				} else {
-					tok.kind = tokenIdentifier
-				}
-
-			default:
-				// This is the default branch for all the symbols for now
-				// may need more granularity
-			}
+	t.spans = t.spans[:0]
+	if t.HasSyntaxHighlight && t.highlighter != nil {
+		t.spans, l.endState = t.highlighter.HighlightLine(runes, l.startState, t.spans)
+	}
+	// Fill the gaps left by the highlighter so every rune is drawn
+	pos := 0
+	for _, span := range t.spans {
+		start := clampOffset(span.Start, len(runes))
+		end := clampOffset(span.End, len(runes))
+		if start < pos || end <= start {
+			continue
 		}
-
-		tok.end = t.lexer.current
-		for i := tok.start; i < tok.end; i += 1 {
-			r := t.charBuf[l.start+i]
-			tok.width += t.Font.GlyphAdvance(r, t.TextSize)
+		if start > pos {
+			t.addToken(l, runes, pos, start, TokenNormal)
 		}
-		l.addToken(tok)
+		t.addToken(l, runes, start, end, span.Kind)
+		pos = end
+	}
+	if pos < len(runes) {
+		t.addToken(l, runes, pos, len(runes), TokenNormal)
+	}
+}
+
+func (t *TextBox) addToken(l *line, runes []rune, start, end int, kind TokenKind) {
+	tok := token{
+		start: start,
+		end:   end,
+		kind:  kind,
 	}
+	for _, r := range runes[start:end] {
+		tok.width += t.glyphAdvance(r)
+	}
+	l.addToken(tok)
 }
 
 func (l *line) addToken(t token) {
 	if l.count >= len(l.tokens) {
-		newbuf := make([]token, len(l.tokens)*2)
+		newbuf := make([]token, len(l.tokens)*2+1)
 		copy(newbuf[:], l.tokens[:len(l.tokens)])
 		l.tokens = newbuf
 	}
@@ -893,34 +1233,38 @@ func (l *line) emptyTokens() {
 	l.count = 0
 }
 
-func (l *lexer) advance() rune {
-	l.current += 1
-	return l.input[l.current-1]
-}
-
-func (l *lexer) peek() rune {
-	return l.input[l.current]
-}
-
-func (l *lexer) eof() bool {
-	return l.current >= len(l.input)
-}
-
-func (l *lexer) isKeyword(word string) bool {
-	for _, keyword := range l.keywords {
-		if string(word) == keyword {
-			return true
+func normalizeNewlines(data []rune) []rune {
+	var result []rune
+	for i, r := range data {
+		if r != '\r' {
+			if result != nil {
+				result = append(result, r)
+			}
+			continue
+		}
+		// Only allocate if there is something to convert
+		if result == nil {
+			result = make([]rune, i, len(data))
+			copy(result, data[:i])
+		}
+		if i+1 >= len(data) || data[i+1] != '\n' {
+			result = append(result, '\n')
 		}
 	}
-	return false
-}
-
-func isDigit(r rune) bool {
-	return r >= '0' && r <= '9'
+	if result == nil {
+		return data
+	}
+	return result
 }
 
-func isLetter(r rune) bool {
-	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+func clampOffset(offset int, length int) int {
+	switch {
+	case offset < 0:
+		return 0
+	case offset > length:
+		return length
+	}
+	return offset
 }
 
 func isTerminalSymbol(r rune) bool {
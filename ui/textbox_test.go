@@ -0,0 +1,340 @@
+package ui
+
+import "testing"
+
+// A monospace font where every glyph is as wide as the text size
+type testFont struct{}
+
+func (f testFont) GlyphAdvance(r rune, size float64) float64 {
+	return size
+}
+
+func (f testFont) MeasureText(text string, size float64) Point {
+	return Point{float64(len([]rune(text))) * size, size}
+}
+
+func newTestTextBox(text string) *TextBox {
+	t := &TextBox{
+		Font:       testFont{},
+		TextSize:   10,
+		Multiline:  true,
+		AutoIndent: true,
+	}
+	t.setRect(Rectangle{Width: 800, Height: 600})
+	t.init()
+	t.LoadBufferData([]rune(text))
+	return t
+}
+
+func checkText(t *testing.T, tb *TextBox, expected string) {
+	t.Helper()
+	if got := string(tb.GetCharBuffer()); got != expected {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestUndoTypingRun(t *testing.T) {
+	tb := newTestTextBox("")
+	for _, r := range "foo bar" {
+		tb.InsertChar(r)
+	}
+	checkText(t, tb, "foo bar")
+
+	tb.Undo()
+	checkText(t, tb, "foo ")
+	if tb.Caret() != 4 {
+		t.Errorf("Caret should be restored to 4, got %d", tb.Caret())
+	}
+	tb.Undo()
+	checkText(t, tb, "")
+
+	tb.Redo()
+	tb.Redo()
+	checkText(t, tb, "foo bar")
+	if tb.Caret() != 7 {
+		t.Errorf("Caret should be restored to 7, got %d", tb.Caret())
+	}
+}
+
+func TestUndoTransaction(t *testing.T) {
+	tb := newTestTextBox("hello\nworld")
+	tb.BeginTransaction()
+	tb.InsertSlice([]rune("a\nb"))
+	tb.MoveCursorLineEnd()
+	tb.ClearSelection()
+	tb.DeleteChar()
+	tb.EndTransaction()
+	checkText(t, tb, "a\nbhell\nworld")
+
+	tb.Undo()
+	checkText(t, tb, "hello\nworld")
+	if tb.CanUndo() {
+		t.Errorf("The transaction should be a single undo step")
+	}
+
+	tb.InsertChar('x')
+	if tb.CanRedo() {
+		t.Errorf("A new edit should clear the redo stack")
+	}
+}
+
+func TestSelectionReplace(t *testing.T) {
+	tb := newTestTextBox("hello world")
+	tb.selectWord(8)
+	if tb.SelectedText() != "world" {
+		t.Fatalf("Expected the word to be selected, got %q", tb.SelectedText())
+	}
+	tb.InsertChar('x')
+	checkText(t, tb, "hello x")
+
+	tb.SetSelection(0, 5)
+	tb.DeleteChar()
+	checkText(t, tb, " x")
+
+	tb.Undo()
+	checkText(t, tb, "hello x")
+	if start, end := tb.Selection(); start != 0 || end != 5 {
+		t.Errorf("The selection should be restored, got [%d, %d)", start, end)
+	}
+}
+
+type testClipboard struct {
+	data string
+}
+
+func (c *testClipboard) ReadClipboard() string   { return c.data }
+func (c *testClipboard) WriteClipboard(s string) { c.data = s }
+
+func TestCutLine(t *testing.T) {
+	clip := &testClipboard{}
+	tb := newTestTextBox("first\nsecond\nthird")
+	tb.SetClipboardCallback(clip)
+
+	tb.SetSelection(8, 8)
+	tb.Cut()
+	checkText(t, tb, "first\nthird")
+	if clip.data != "second\n" {
+		t.Errorf("Expected the whole line in the clipboard, got %q", clip.data)
+	}
+
+	tb.SetSelection(0, 3)
+	tb.Copy()
+	if clip.data != "fir" {
+		t.Errorf("Expected the selection in the clipboard, got %q", clip.data)
+	}
+}
+
+func TestScrollKeepsCaretVisible(t *testing.T) {
+	text := ""
+	for i := 0; i < 200; i += 1 {
+		text += "line\n"
+	}
+	tb := newTestTextBox(text)
+	tb.moveCursorByPage(1)
+	tb.moveCursorByPage(1)
+	if tb.CurrentLine() != 121 {
+		t.Fatalf("Expected the caret two pages down, got line %d", tb.CurrentLine())
+	}
+	first, last := tb.visibleLines()
+	if tb.lineIndex < first || tb.lineIndex >= last {
+		t.Errorf("Caret line %d is outside of the view [%d, %d)", tb.lineIndex, first, last)
+	}
+
+	tb.SetSelection(0, 0)
+	if tb.scrollLine != 0 {
+		t.Errorf("The view should follow the caret back to the top, got %d", tb.scrollLine)
+	}
+	if tb.cursor.Y != tb.activeRect.Y {
+		t.Errorf("The cursor should be drawn on the first line, got %f", tb.cursor.Y)
+	}
+}
+
+func TestLoadNormalizesNewlines(t *testing.T) {
+	tb := newTestTextBox("a\r\nb\rc\nd")
+	checkText(t, tb, "a\nb\nc\nd")
+	if tb.Buffer().LineCount() != 4 {
+		t.Errorf("Expected 4 lines, got %d", tb.Buffer().LineCount())
+	}
+
+	tb.SetSelection(1, 1)
+	tb.InsertSlice([]rune("x\r\ny"))
+	checkText(t, tb, "ax\ny\nb\nc\nd")
+}
+
+// Mark each "x" as a keyword. A '"' opens a string
+// that goes on until the next one, on any line
+type testHighlighter struct {
+	calls int
+}
+
+func (h *testHighlighter) HighlightLine(line []rune, state LineState, tokens []Token) ([]Token, LineState) {
+	h.calls += 1
+	for i, r := range line {
+		switch {
+		case r == '"':
+			state = 1 - state
+			tokens = append(tokens, Token{Start: i, End: i + 1, Kind: TokenString})
+		case state == 1:
+			tokens = append(tokens, Token{Start: i, End: i + 1, Kind: TokenString})
+		case r == 'x':
+			tokens = append(tokens, Token{Start: i, End: i + 1, Kind: TokenKeyword})
+		}
+	}
+	return tokens, state
+}
+
+func TestHighlighterGaps(t *testing.T) {
+	tb := newTestTextBox("ab x cd\nx")
+	tb.HasSyntaxHighlight = true
+	tb.SetHighlighter(&testHighlighter{})
+
+	l := tb.lines[0]
+	expected := []token{
+		{start: 0, end: 3, width: 30, kind: TokenNormal},
+		{start: 3, end: 4, width: 10, kind: TokenKeyword},
+		{start: 4, end: 7, width: 30, kind: TokenNormal},
+	}
+	if l.count != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d", len(expected), l.count)
+	}
+	for i, tok := range expected {
+		if l.tokens[i] != tok {
+			t.Errorf("Token %d: expected %+v, got %+v", i, tok, l.tokens[i])
+		}
+	}
+
+	tb.SetSelection(9, 9)
+	tb.InsertChar('y')
+	if l := tb.lines[1]; l.count != 2 || l.tokens[0].kind != TokenKeyword || l.tokens[1].kind != TokenNormal {
+		t.Errorf("The edited line should be highlighted again, got %+v", l.tokens[:l.count])
+	}
+}
+
+func TestHighlighterStateStabilizes(t *testing.T) {
+	text := ""
+	for i := 0; i < 100; i += 1 {
+		text += "x\n"
+	}
+	tb := newTestTextBox(text)
+	tb.HasSyntaxHighlight = true
+	h := &testHighlighter{}
+	tb.SetHighlighter(h)
+
+	// A single line edit only lexes the line
+	h.calls = 0
+	tb.SetSelection(0, 0)
+	tb.InsertChar('a')
+	if h.calls != 1 {
+		t.Errorf("Only the edited line should be lexed again, got %d calls", h.calls)
+	}
+
+	// Opening a string changes all the following lines
+	tb.SetSelection(10, 10)
+	tb.InsertChar('"')
+	if kind := tb.lines[50].tokens[0].kind; kind != TokenString {
+		t.Errorf("The lines after the quote should be in a string, got %d", kind)
+	}
+
+	// Removing the line holding the quote. The following lines
+	// were lexed after it and must be lexed again
+	h.calls = 0
+	tb.SetSelection(8, 11)
+	tb.DeleteChar()
+	if kind := tb.lines[50].tokens[0].kind; kind != TokenKeyword {
+		t.Errorf("Deleting the quote shouldn't leave a string, got %d", kind)
+	}
+	if h.calls != len(tb.lines)-3 {
+		t.Errorf("All the lines after the edit should be lexed again, got %d calls", h.calls)
+	}
+
+	// Closing the string right away only changes the line
+	tb.SetSelection(10, 10)
+	tb.InsertChar('"')
+	h.calls = 0
+	tb.InsertChar('"')
+	if kind := tb.lines[50].tokens[0].kind; kind != TokenKeyword {
+		t.Errorf("The string should be closed, got %d", kind)
+	}
+	if h.calls != len(tb.lines)-4 {
+		t.Errorf("The lines in the string should be lexed again, got %d calls", h.calls)
+	}
+	h.calls = 0
+	tb.InsertChar('a')
+	if h.calls != 1 {
+		t.Errorf("The lexing should stop once the state is stable, got %d calls", h.calls)
+	}
+}
+
+func TestTabSize(t *testing.T) {
+	tb := newTestTextBox("\ta")
+	if w := tb.measureRange(0, 2); w != 20 {
+		t.Fatalf("Without a tab size a tab is a single glyph, expected 20, got %v", w)
+	}
+	tb.SetTabSize(4)
+	if w := tb.measureRange(0, 2); w != 50 {
+		t.Errorf("Expected a tab as wide as 4 spaces, got a width of %v", w)
+	}
+	var width float64
+	for _, tok := range tb.lines[0].tokens[:tb.lines[0].count] {
+		width += tok.width
+	}
+	if width != 50 {
+		t.Errorf("The cached token widths should follow the tab size, got %v", width)
+	}
+}
+
+func TestJumpTo(t *testing.T) {
+	text := ""
+	for i := 0; i < 200; i += 1 {
+		text += "line\n"
+	}
+	tb := newTestTextBox(text)
+	tb.JumpTo(100, 2)
+	if tb.CurrentLine() != 100 || tb.CurrentColumn() != 2 {
+		t.Fatalf("Expected the caret at 100:2, got %d:%d", tb.CurrentLine(), tb.CurrentColumn())
+	}
+	if middle := tb.scrollLine + tb.lineRenderCount/2; middle != 99 {
+		t.Errorf("The line should be in the middle of the view, got the view from line %d", tb.scrollLine)
+	}
+	if expected := tb.lineOrigin(99)[1]; tb.cursor.Y != expected {
+		t.Errorf("The cursor should be drawn on the line, got y %f instead of %f", tb.cursor.Y, expected)
+	}
+
+	// Out of range values are clamped
+	tb.JumpTo(1000, 50)
+	if tb.CurrentLine() != 201 || tb.CurrentColumn() != 0 {
+		t.Errorf("Expected the caret on the last line, got %d:%d", tb.CurrentLine(), tb.CurrentColumn())
+	}
+	first, last := tb.visibleLines()
+	if tb.lineIndex < first || tb.lineIndex >= last {
+		t.Errorf("Caret line %d is outside of the view [%d, %d)", tb.lineIndex, first, last)
+	}
+	tb.JumpTo(-3, 50)
+	if tb.CurrentLine() != 1 || tb.CurrentColumn() != 4 {
+		t.Errorf("Expected the caret at the end of the first line, got %d:%d", tb.CurrentLine(), tb.CurrentColumn())
+	}
+}
+
+func TestReplaceBufferData(t *testing.T) {
+	tb := newTestTextBox("func f(){\nx:=1\n}\nvar y = 2\n")
+	// On the 'y' of the last line
+	tb.SetSelection(21, 21)
+	tb.ReplaceBufferData([]rune("func f() {\n\tx := 1\n}\r\nvar y = 2\n"))
+	checkText(t, tb, "func f() {\n\tx := 1\n}\nvar y = 2\n")
+	if tb.CurrentLine() != 4 || tb.CurrentColumn() != 4 {
+		t.Errorf("The caret should stay on the 'y' at 4:4, got %d:%d", tb.CurrentLine(), tb.CurrentColumn())
+	}
+
+	tb.Undo()
+	checkText(t, tb, "func f(){\nx:=1\n}\nvar y = 2\n")
+	if tb.CanUndo() {
+		t.Errorf("The replacement should be a single undo step")
+	}
+
+	// Inside of the changed runes, the line and column are kept
+	tb.SetSelection(12, 12)
+	tb.ReplaceBufferData([]rune("func f() {\n\tx := 1\n}\nvar y = 2\n"))
+	if tb.CurrentLine() != 2 || tb.CurrentColumn() != 2 {
+		t.Errorf("The caret should stay at 2:2, got %d:%d", tb.CurrentLine(), tb.CurrentColumn())
+	}
+}
@@ -0,0 +1,241 @@
+package ui
+
+const textBufferMinCap = 64
+
+// A gap buffer of runes used as the storage of the TextBox.
+// Lines are terminated by a single '\n'.
+//
+// Edits are done at the gap, so typing at the same place only costs
+// the size of the edit. Moving the gap costs the distance travelled.
+//
+// The starts of the lines are stored in a second gap buffer that follows
+// the text one. The starts before the line gap are absolute offsets while
+// the ones after it are stored as a distance from the end of the text.
+// That way an edit never has to shift the lines that follow it.
+type TextBuffer struct {
+	data     []rune
+	gapStart int
+	gapEnd   int
+
+	lines        []int
+	lineGapStart int
+	lineGapEnd   int
+}
+
+func NewTextBuffer(cap int) TextBuffer {
+	b := TextBuffer{}
+	b.init(cap)
+	return b
+}
+
+func (b *TextBuffer) init(cap int) {
+	if cap < textBufferMinCap {
+		cap = textBufferMinCap
+	}
+	b.data = make([]rune, cap)
+	b.gapStart = 0
+	b.gapEnd = cap
+	b.lines = make([]int, textBufferMinCap)
+	b.lines[0] = 0
+	b.lineGapStart = 1
+	b.lineGapEnd = len(b.lines)
+}
+
+// Replace the whole content of the buffer
+func (b *TextBuffer) Reset(data []rune) {
+	cap := len(b.data)
+	if cap < len(data)*2 {
+		cap = len(data) * 2
+	}
+	b.init(cap)
+	copy(b.data, data)
+	b.gapStart = len(data)
+	for i, r := range data {
+		if r == '\n' {
+			b.insertLineStart(i + 1)
+		}
+	}
+}
+
+// The number of runes stored
+func (b *TextBuffer) Len() int {
+	return len(b.data) - b.gapLen()
+}
+
+func (b *TextBuffer) RuneAt(offset int) rune {
+	if offset < b.gapStart {
+		return b.data[offset]
+	}
+	return b.data[offset+b.gapLen()]
+}
+
+// Return a copy of the runes in [start, end)
+func (b *TextBuffer) Slice(start, end int) []rune {
+	result := make([]rune, end-start)
+	b.copySlice(result, start, end)
+	return result
+}
+
+// Return a copy of the whole content
+func (b *TextBuffer) Runes() []rune {
+	return b.Slice(0, b.Len())
+}
+
+func (b *TextBuffer) String() string {
+	return string(b.Runes())
+}
+
+func (b *TextBuffer) Insert(offset int, data []rune) {
+	if len(data) == 0 {
+		return
+	}
+	if b.lines == nil {
+		b.init(textBufferMinCap)
+	}
+	// All the lines after the edited one are relative
+	// to the end, so they stay valid after the insertion
+	b.moveLineGap(b.LineAt(offset) + 1)
+
+	b.moveGap(offset)
+	b.grow(len(data))
+	copy(b.data[b.gapStart:], data)
+	b.gapStart += len(data)
+
+	for i, r := range data {
+		if r == '\n' {
+			b.insertLineStart(offset + i + 1)
+		}
+	}
+}
+
+// Delete the runes in [start, end)
+func (b *TextBuffer) Delete(start, end int) {
+	if start >= end {
+		return
+	}
+	b.moveLineGap(b.LineAt(start) + 1)
+	// Drop the lines that begin inside the deleted range
+	length := b.Len()
+	for b.lineGapEnd < len(b.lines) && length-b.lines[b.lineGapEnd] <= end {
+		b.lineGapEnd += 1
+	}
+
+	b.moveGap(start)
+	b.gapEnd += end - start
+}
+
+func (b *TextBuffer) LineCount() int {
+	return len(b.lines) - b.lineGapLen()
+}
+
+// Offset of the first rune of the line
+func (b *TextBuffer) LineStart(line int) int {
+	if line < b.lineGapStart {
+		return b.lines[line]
+	}
+	return b.Len() - b.lines[line+b.lineGapLen()]
+}
+
+// Offset right after the last rune of the line,
+// the '\n' terminator excluded
+func (b *TextBuffer) LineEnd(line int) int {
+	if line+1 >= b.LineCount() {
+		return b.Len()
+	}
+	return b.LineStart(line+1) - 1
+}
+
+// Index of the line containing the given offset
+func (b *TextBuffer) LineAt(offset int) int {
+	low, high := 0, b.LineCount()-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if b.LineStart(mid) <= offset {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+func (b *TextBuffer) gapLen() int {
+	return b.gapEnd - b.gapStart
+}
+
+func (b *TextBuffer) copySlice(dst []rune, start, end int) {
+	switch {
+	case end <= b.gapStart:
+		copy(dst, b.data[start:end])
+	case start >= b.gapStart:
+		copy(dst, b.data[start+b.gapLen():end+b.gapLen()])
+	default:
+		n := copy(dst, b.data[start:b.gapStart])
+		copy(dst[n:], b.data[b.gapEnd:end+b.gapLen()])
+	}
+}
+
+func (b *TextBuffer) moveGap(offset int) {
+	switch {
+	case offset < b.gapStart:
+		n := b.gapStart - offset
+		copy(b.data[b.gapEnd-n:b.gapEnd], b.data[offset:b.gapStart])
+		b.gapStart -= n
+		b.gapEnd -= n
+	case offset > b.gapStart:
+		n := offset - b.gapStart
+		copy(b.data[b.gapStart:], b.data[b.gapEnd:b.gapEnd+n])
+		b.gapStart += n
+		b.gapEnd += n
+	}
+}
+
+func (b *TextBuffer) grow(n int) {
+	if b.gapLen() >= n {
+		return
+	}
+	newCap := len(b.data) * 2
+	if newCap-b.Len() < n {
+		newCap = b.Len() + n*2
+	}
+	newBuf := make([]rune, newCap)
+	copy(newBuf, b.data[:b.gapStart])
+	tail := len(b.data) - b.gapEnd
+	copy(newBuf[newCap-tail:], b.data[b.gapEnd:])
+	b.gapEnd = newCap - tail
+	b.data = newBuf
+}
+
+func (b *TextBuffer) lineGapLen() int {
+	return b.lineGapEnd - b.lineGapStart
+}
+
+// Move the line gap right before the given line. The moved starts are
+// converted between absolute and end relative offsets
+func (b *TextBuffer) moveLineGap(line int) {
+	length := b.Len()
+	for b.lineGapStart > line {
+		b.lineGapStart -= 1
+		b.lineGapEnd -= 1
+		b.lines[b.lineGapEnd] = length - b.lines[b.lineGapStart]
+	}
+	for b.lineGapStart < line {
+		b.lines[b.lineGapStart] = length - b.lines[b.lineGapEnd]
+		b.lineGapStart += 1
+		b.lineGapEnd += 1
+	}
+}
+
+// Insert an absolute line start at the line gap
+func (b *TextBuffer) insertLineStart(start int) {
+	if b.lineGapLen() == 0 {
+		newBuf := make([]int, len(b.lines)*2)
+		copy(newBuf, b.lines[:b.lineGapStart])
+		tail := len(b.lines) - b.lineGapEnd
+		copy(newBuf[len(newBuf)-tail:], b.lines[b.lineGapEnd:])
+		b.lineGapEnd = len(newBuf) - tail
+		b.lines = newBuf
+	}
+	b.lines[b.lineGapStart] = start
+	b.lineGapStart += 1
+}
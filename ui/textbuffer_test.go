@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func checkBuffer(t *testing.T, b *TextBuffer, expected string) {
+	t.Helper()
+	if b.String() != expected {
+		t.Fatalf("Buffer content mismatch, expected %q got %q", expected, b.String())
+	}
+	lines := strings.Split(expected, "\n")
+	if b.LineCount() != len(lines) {
+		t.Fatalf("Expected %d lines, got %d", len(lines), b.LineCount())
+	}
+	offset := 0
+	for i, l := range lines {
+		if b.LineStart(i) != offset {
+			t.Fatalf("Line %d should start at %d, got %d", i, offset, b.LineStart(i))
+		}
+		if b.LineEnd(i) != offset+len([]rune(l)) {
+			t.Fatalf("Line %d should end at %d, got %d", i, offset+len([]rune(l)), b.LineEnd(i))
+		}
+		if b.LineAt(offset) != i {
+			t.Fatalf("Offset %d should be on line %d, got %d", offset, i, b.LineAt(offset))
+		}
+		offset += len([]rune(l)) + 1
+	}
+}
+
+func TestTextBufferReset(t *testing.T) {
+	b := NewTextBuffer(0)
+	checkBuffer(t, &b, "")
+
+	input := "package main\n\nfunc main() {\n}\n"
+	b.Reset([]rune(input))
+	checkBuffer(t, &b, input)
+}
+
+func TestTextBufferGrowth(t *testing.T) {
+	b := NewTextBuffer(0)
+	expected := ""
+	for i := 0; i < 5000; i += 1 {
+		b.Insert(b.Len(), []rune("a\n"))
+		expected += "a\n"
+	}
+	checkBuffer(t, &b, expected)
+}
+
+func TestTextBufferRandomEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []rune("ab \n\té")
+
+	b := NewTextBuffer(0)
+	var expected []rune
+	for i := 0; i < 2000; i += 1 {
+		if len(expected) > 0 && rng.Intn(3) == 0 {
+			start := rng.Intn(len(expected))
+			end := start + rng.Intn(len(expected)-start+1)
+			b.Delete(start, end)
+			expected = append(expected[:start], expected[end:]...)
+		} else {
+			offset := rng.Intn(len(expected) + 1)
+			data := make([]rune, rng.Intn(8)+1)
+			for j := range data {
+				data[j] = alphabet[rng.Intn(len(alphabet))]
+			}
+			b.Insert(offset, data)
+			expected = append(expected[:offset], append(data, expected[offset:]...)...)
+		}
+		checkBuffer(t, &b, string(expected))
+	}
+}
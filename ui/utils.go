@@ -1,7 +1,9 @@
 package ui
 
 import (
+	"fmt"
 	"log"
+	"strconv"
 	"sync/atomic"
 )
 
@@ -87,6 +89,23 @@ func (c Color) RGBA() (r, g, b, a uint32) {
 	return
 }
 
+// Read a color written as "#RRGGBB" or "#RRGGBBAA",
+// the alpha defaults to 255
+func ParseHexColor(s string) (Color, error) {
+	if len(s) != 7 && len(s) != 9 || s[0] != '#' {
+		return Color{}, fmt.Errorf("%q is not a #RRGGBB or #RRGGBBAA color", s)
+	}
+	clr := Color{0, 0, 0, 255}
+	for i := 0; i < (len(s)-1)/2; i += 1 {
+		c, err := strconv.ParseUint(s[1+i*2:3+i*2], 16, 8)
+		if err != nil {
+			return Color{}, fmt.Errorf("%q is not a #RRGGBB or #RRGGBBAA color", s)
+		}
+		clr[i] = uint8(c)
+	}
+	return clr, nil
+}
+
 func (r Rectangle) pointInBounds(p Point) bool {
 	return (p[0] >= r.X && p[0] <= r.X+r.Width) && (p[1] >= r.Y && p[1] <= r.Y+r.Height)
 }
@@ -212,6 +231,8 @@ const (
 	keyCopy
 	keyCut
 	keyPaste
+	keyPageUp
+	keyPageDown
 	keyMax
 )
 
@@ -221,6 +242,7 @@ type (
 		mLeft             bool
 		previousmPos      Point
 		previousmLeft     bool
+		wheel             Point
 		pressedChars      [charPressedCap]rune
 		pressedCharsCount int32
 
@@ -232,6 +254,8 @@ type (
 	Input struct {
 		MPos  Point
 		MLeft bool
+		// The mouse wheel movement since the last update
+		Wheel Point
 
 		// all the mods and keys the UI cares about
 		Esc   bool
@@ -250,6 +274,9 @@ type (
 		Copy  bool
 		Cut   bool
 		Paste bool
+
+		PageUp   bool
+		PageDown bool
 	}
 
 	CursorShape int
@@ -264,6 +291,10 @@ func mousePosition() Point {
 	return ctx.input.mPos
 }
 
+func mouseWheel() Point {
+	return ctx.input.wheel
+}
+
 func isMousePressed() bool {
 	return ctx.input.mLeft
 }
@@ -293,6 +324,10 @@ func isAnyKeyPressed(keys []key) bool {
 	return false
 }
 
+func isKeyJustPressed(k key) bool {
+	return ctx.input.keyCounts[k] == 1
+}
+
 func isKeyRepeated(k key) bool {
 	const (
 		delay    = 15
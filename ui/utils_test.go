@@ -0,0 +1,26 @@
+package ui
+
+import "testing"
+
+func TestParseHexColor(t *testing.T) {
+	valid := map[string]Color{
+		"#000000":   {0, 0, 0, 255},
+		"#F7E7e6":   {247, 231, 230, 255},
+		"#ff5f8380": {255, 95, 131, 128},
+	}
+	for s, expected := range valid {
+		clr, err := ParseHexColor(s)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", s, err)
+		} else if clr != expected {
+			t.Errorf("Expected %v for %q, got %v", expected, s, clr)
+		}
+	}
+
+	invalid := []string{"", "#", "FFFFFF", "#FFF", "#FFFFFG", "#FFFFFFF", "#+1FFFFF"}
+	for _, s := range invalid {
+		if _, err := ParseHexColor(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
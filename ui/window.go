@@ -27,6 +27,14 @@ type Window struct {
 
 	MinimizeBtn Button
 	CloseBtn    Button
+
+	// Drawn under the other windows even with the focus,
+	// like the main window of an application
+	Backdrop bool
+	// A click gives the focus to the window, and a click on the
+	// backdrop takes it back. For the panels left open while
+	// working in the backdrop
+	FocusOnClick bool
 }
 
 func (win *Window) initWindow() {
@@ -166,6 +174,31 @@ func (w *Window) setMinimizeBtn(btn Button) {
 	})
 }
 
+// The colors of a window and of its header buttons,
+// to restyle it after it has been added
+type WindowColors struct {
+	Background Color
+	Header     Color
+	HeaderFont Color
+	Border     Color
+	Button     Color
+	ButtonIcon Color
+}
+
+func (w *Window) setColors(c WindowColors) {
+	w.Background.Clr = c.Background
+	w.HeaderBackground.Clr = c.Header
+	w.HeaderFontClr = c.HeaderFont
+	w.BorderColor = c.Border
+	for _, btn := range []*Button{&w.CloseBtn, &w.MinimizeBtn} {
+		btn.Clr = c.Button
+		btn.HighlightClr = c.Button
+		btn.PressedClr = c.Button
+		btn.Background.Clr = c.Button
+		btn.IconClr = c.ButtonIcon
+	}
+}
+
 type WinHandle struct {
 	id  int
 	gen uint
@@ -185,6 +218,11 @@ func (h WinHandle) IsActive() bool {
 	return getWindow(h).Active
 }
 
+// Check if the window is above the other ones
+func (h WinHandle) IsFocused() bool {
+	return getWindow(h).zIndex == 0
+}
+
 func (h WinHandle) SetCloseBtn(btn Button) {
 	getWindow(h).setCloseBtn(btn)
 }
@@ -193,6 +231,10 @@ func (h WinHandle) SetMinimizeBtn(btn Button) {
 	getWindow(h).setMinimizeBtn(btn)
 }
 
+func (h WinHandle) SetColors(c WindowColors) {
+	getWindow(h).setColors(c)
+}
+
 func (h WinHandle) AddWidget(wgt Widget, length int) {
 	getWindow(h).AddWidget(wgt, length)
 }